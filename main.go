@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/mind-rot/dbfs/email"
@@ -13,13 +15,31 @@ import (
 )
 
 type Config struct {
-	APP_PORT            string `env:"APP_PORT" envDefault:"8080"`
-	DB_PATH             string `env:"DB_PATH" envDefault:"/tmp/mydb.bolt"`
-	MAILGUN_API_KEY     string `env:"MAILGUN_API_KEY" envDefault:""`
-	MAILGUN_ROOT_DOMAIN string `env:"MAILGUN_ROOT_DOMAIN"`
-	MAILGUN_SUBDOMAIN   string `env:"MAILGUN_SUBDOMAIN" envDefault:""`
-	WHITELIST           string `env:"WHITELIST"`
-	A                   string `env:"A"`
+	APP_PORT            string        `env:"APP_PORT" envDefault:"8080"`
+	DB_PATH             string        `env:"DB_PATH" envDefault:"/tmp/mydb.bolt"`
+	MAILGUN_API_KEY     string        `env:"MAILGUN_API_KEY" envDefault:""`
+	MAILGUN_ROOT_DOMAIN string        `env:"MAILGUN_ROOT_DOMAIN"`
+	MAILGUN_SUBDOMAIN   string        `env:"MAILGUN_SUBDOMAIN" envDefault:""`
+	WHITELIST           string        `env:"WHITELIST"`
+	ADMINS              string        `env:"ADMINS"`
+	SESSION_TTL         time.Duration `env:"SESSION_TTL" envDefault:"24h"`
+	INVITE_ALLOWANCE    int           `env:"INVITE_ALLOWANCE" envDefault:"5"`
+	UPLOAD_TTL          time.Duration `env:"UPLOAD_TTL" envDefault:"24h"`
+	UPLOAD_MAX_SIZE     int64         `env:"UPLOAD_MAX_SIZE" envDefault:"0"`
+	MAX_NAME_LENGTH     int           `env:"MAX_NAME_LENGTH" envDefault:"255"`
+	MAX_PATH_DEPTH      int           `env:"MAX_PATH_DEPTH" envDefault:"32"`
+	SWEEP_INTERVAL      time.Duration `env:"SWEEP_INTERVAL" envDefault:"1m"`
+	PUBLIC_URL          string        `env:"PUBLIC_URL"`
+	SHARE_EMAIL_LIMIT   int           `env:"SHARE_EMAIL_LIMIT" envDefault:"20"`
+	SHARE_EMAIL_WINDOW  time.Duration `env:"SHARE_EMAIL_WINDOW" envDefault:"1h"`
+	SITE_MAX_AGE        time.Duration `env:"SITE_MAX_AGE" envDefault:"5m"`
+	RECOVERY_TTL        time.Duration `env:"RECOVERY_TTL" envDefault:"1h"`
+	SIGNUP_TTL          time.Duration `env:"SIGNUP_TTL" envDefault:"30m"`
+	SECRET_KEY          string        `env:"SECRET_KEY"`
+	AUTHENTICATORS      string        `env:"AUTHENTICATORS" envDefault:"token,bearer,basic"`
+	PROXY_AUTH_HEADER   string        `env:"PROXY_AUTH_HEADER"`
+	TRUSTED_PROXIES     string        `env:"TRUSTED_PROXIES"`
+	A                   string        `env:"A"`
 }
 
 func main() {
@@ -29,17 +49,71 @@ func main() {
 		log.Fatal(err)
 	}
 
+	s := &store.Store{
+		Path:          config.DB_PATH,
+		MaxNameLength: config.MAX_NAME_LENGTH,
+		MaxDepth:      config.MAX_PATH_DEPTH,
+	}
+	if err := s.Migrate(); err != nil {
+		log.Fatal(err)
+	}
+	// env whitelist is only initial content, later it is managed with admin routes
+	if err := s.SeedWhitelist(strings.Split(config.WHITELIST, ",")); err != nil {
+		log.Fatal(err)
+	}
+	go sweep(s, config.SWEEP_INTERVAL)
+
+	authenticators, err := rest.NewAuthenticators(config.AUTHENTICATORS, s, config.PROXY_AUTH_HEADER, config.TRUSTED_PROXIES)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "error configuring authentication"))
+	}
+
 	r := &rest.Rest{
-		Store: &store.Store{
-			Path: config.DB_PATH,
-		},
-		Email:     email.New(config.MAILGUN_API_KEY, config.MAILGUN_ROOT_DOMAIN, config.MAILGUN_SUBDOMAIN),
-		Whitelist: config.WHITELIST,
+		Store:         s,
+		Email:         email.New(config.MAILGUN_API_KEY, config.MAILGUN_ROOT_DOMAIN, config.MAILGUN_SUBDOMAIN),
+		Admins:        config.ADMINS,
+		UploadTTL:     config.UPLOAD_TTL,
+		MaxUploadSize: config.UPLOAD_MAX_SIZE,
+
+		PublicURL:        config.PUBLIC_URL,
+		ShareEmailLimit:  config.SHARE_EMAIL_LIMIT,
+		ShareEmailWindow: config.SHARE_EMAIL_WINDOW,
+		SiteMaxAge:       config.SITE_MAX_AGE,
+		RecoveryTTL:      config.RECOVERY_TTL,
+		SignupTTL:        config.SIGNUP_TTL,
+		Secret:           config.SECRET_KEY,
+		InviteAllowance:  config.INVITE_ALLOWANCE,
+		SessionTTL:       config.SESSION_TTL,
+		Authenticators:   authenticators,
 	}
 
 	fmt.Println("starting dbfs on localhost:" + config.APP_PORT)
-	err := http.ListenAndServe(":"+config.APP_PORT, r.Router())
+	err = http.ListenAndServe(":"+config.APP_PORT, r.Router())
 	if err != nil {
 		log.Fatal(errors.Wrap(err, "error starting dbfs server"))
 	}
 }
+
+// sweep periodically removes expired shares, uploads, recovery codes, signups, sessions and used presigned urls
+func sweep(s *store.Store, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.DeleteExpiredShares(); err != nil {
+			log.Printf("[ERROR] %s", err)
+		}
+		if err := s.DeleteExpiredUploads(); err != nil {
+			log.Printf("[ERROR] %s", err)
+		}
+		if err := s.DeleteExpiredRecoveries(); err != nil {
+			log.Printf("[ERROR] %s", err)
+		}
+		if err := s.DeleteExpiredSignups(); err != nil {
+			log.Printf("[ERROR] %s", err)
+		}
+		if err := s.DeleteExpiredSessions(); err != nil {
+			log.Printf("[ERROR] %s", err)
+		}
+		if err := s.DeleteExpiredPresigned(); err != nil {
+			log.Printf("[ERROR] %s", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/mind-rot/dbfs/store"
+)
+
+// account returns account resolved by authenticate, nil is returned for anonymous request
+func account(r *http.Request) *store.Account {
+	if principal := principal(r); principal != nil {
+		return principal.Account
+	}
+	return nil
+}
+
+// accountID returns ID of request account, which is also name of its collection
+// empty string is returned for anonymous request
+func accountID(r *http.Request) string {
+	if account := account(r); account != nil {
+		return account.ID
+	}
+	return ""
+}
+
+// apiKey returns API key request was authenticated with, nil is returned for main token
+func apiKey(r *http.Request) *store.APIKey {
+	if principal := principal(r); principal != nil {
+		return principal.Key
+	}
+	return nil
+}
+
+// authorize checks that API key of request has scope, main token allows everything
+// it is used for operations, which are not bound to single path
+func authorize(w http.ResponseWriter, r *http.Request, scope string) bool {
+	key := apiKey(r)
+	if key == nil || key.HasScope(scope) {
+		return true
+	}
+
+	sendErrStatus(w, nil, "token does not allow "+scope, http.StatusForbidden)
+	return false
+}
+
+// authorizePath checks that API key of request has scope and its prefix covers path
+func authorizePath(w http.ResponseWriter, r *http.Request, scope string, keys []string) bool {
+	if !authorize(w, r, scope) {
+		return false
+	}
+
+	if key := apiKey(r); key != nil && !key.Covers(keys) {
+		sendErrStatus(w, nil, "path is outside of token prefix", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// covers checks if path is available for request, used for filtering lists
+func covers(r *http.Request, keys []string) bool {
+	key := apiKey(r)
+	return key == nil || key.Covers(keys)
+}
+
+// authorizeShare checks that API key of request could manage share given by token
+// share outside of key prefix is reported as not found, like shares of other users
+func (rest *Rest) authorizeShare(w http.ResponseWriter, r *http.Request, collection string, token string) bool {
+	if !authorize(w, r, store.ScopeShare) {
+		return false
+	}
+	if apiKey(r) == nil {
+		return true
+	}
+
+	share, err := rest.Store.GetShare(collection, token)
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot get share", http.StatusInternalServerError)
+		return false
+	}
+	if !covers(r, share.Path) {
+		sendShareErr(w, store.ErrShareNotFound, "cannot get share")
+		return false
+	}
+	return true
+}
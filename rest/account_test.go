@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccount(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	mailer := &recordingEmail{sent: make(map[string]string)}
+	r.Email = mailer
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	token := registerAccount(t, ts, mailer, "random@gmail.com")
+	require.NotEmpty(t, token)
+
+	account, _, err := r.Store.Authenticate(token)
+	require.Nil(t, err)
+	assert.NotEqual(t, token, account.ID)
+
+	tt := []struct {
+		Token  string
+		Status int
+		Body   string
+	}{
+		{token, http.StatusOK, "file\n"},
+		{account.ID, http.StatusUnauthorized, "invalid token"},
+		{"", http.StatusOK, "empty Authorization header"},
+	}
+
+	require.Nil(t, r.Store.Put(account.ID, []string{"file"}, strings.NewReader("data")))
+	for _, test := range tt {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+basePath, nil)
+		require.Nil(t, err)
+		req.Header.Set("Authorization", test.Token)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		assert.Equal(t, test.Status, resp.StatusCode, test.Token)
+		assert.Equal(t, test.Body, string(msg))
+	}
+
+	// public routes ignore missing header
+	resp, err := http.Get(ts.URL + "/help")
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
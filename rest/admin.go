@@ -0,0 +1,276 @@
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+// adminRoutes maps server management available only for admins
+func (rest *Rest) adminRoutes(router *mux.Router) {
+	adminSubrouter := router.PathPrefix(adminPath).Subrouter()
+	adminSubrouter.HandleFunc("/whitelist", rest.listWhitelist).Methods("GET")
+	adminSubrouter.HandleFunc("/whitelist", rest.addWhitelistRule).Methods("POST")
+	adminSubrouter.HandleFunc("/whitelist/{id}", rest.deleteWhitelistRule).Methods("DELETE")
+	adminSubrouter.HandleFunc("/invites", rest.listAllInvites).Methods("GET")
+	adminSubrouter.HandleFunc("/accounts", rest.listAccounts).Methods("GET")
+	adminSubrouter.HandleFunc("/accounts/{id}", rest.deleteAccount).Methods("DELETE")
+	adminSubrouter.HandleFunc("/accounts/{id}/role", rest.setRole).Methods("PUT")
+	adminSubrouter.HandleFunc("/accounts/{id}/suspend", rest.suspendAccount(true)).Methods("POST")
+	adminSubrouter.HandleFunc("/accounts/{id}/unsuspend", rest.suspendAccount(false)).Methods("POST")
+	adminSubrouter.HandleFunc("/accounts/{id}/rotate", rest.forceRotateToken).Methods("POST")
+	adminSubrouter.HandleFunc("/stats", rest.stats).Methods("GET")
+}
+
+// requireAdmin checks that request is made by admin with main token
+func (rest *Rest) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := mainToken(w, r); !ok {
+		return false
+	}
+	if !rest.isAdmin(account(r)) {
+		sendErrStatus(w, nil, "admin role required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// isAdmin checks if account has admin role or is listed in Admins
+// Admins bootstraps the role, so listed accounts could not lose it
+func (rest *Rest) isAdmin(account *store.Account) bool {
+	if account.Role == store.RoleAdmin {
+		return true
+	}
+
+	email := strings.ToLower(account.Email)
+	for _, admin := range strings.Split(rest.Admins, ",") {
+		if email != "" && email == strings.ToLower(strings.TrimSpace(admin)) {
+			return true
+		}
+	}
+	return false
+}
+
+// listWhitelist returns all registration rules
+func (rest *Rest) listWhitelist(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	rules, err := rest.Store.ListWhitelistRules()
+	if err != nil {
+		sendErrStatus(w, err, "cannot list whitelist", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, rules)
+}
+
+type whitelistRuleRequest struct {
+	// Pattern is email, "*@domain" or regular expression
+	Pattern string `json:"pattern"`
+	// Kind is "exact", "domain" or "regex", it is guessed from pattern when empty
+	Kind string `json:"kind"`
+	Deny bool   `json:"deny"`
+}
+
+// addWhitelistRule adds registration rule
+func (rest *Rest) addWhitelistRule(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	req := &whitelistRuleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := rest.Store.AddWhitelistRule(store.WhitelistRule{
+		ID:      randomToken(8),
+		Kind:    req.Kind,
+		Pattern: req.Pattern,
+		Deny:    req.Deny,
+	})
+	if errors.Cause(err) == store.ErrInvalidRule {
+		sendErrStatus(w, err, "invalid whitelist rule", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		sendErrStatus(w, err, "cannot add whitelist rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	sendJSON(w, rule)
+}
+
+// deleteWhitelistRule removes registration rule
+func (rest *Rest) deleteWhitelistRule(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	err := rest.Store.DeleteWhitelistRule(mux.Vars(r)["id"])
+	if errors.Cause(err) == store.ErrRuleNotFound {
+		sendErrStatus(w, err, "whitelist rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrStatus(w, err, "cannot delete whitelist rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listAllInvites returns invites of all users together with registrations made with them
+func (rest *Rest) listAllInvites(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	invites, err := rest.Store.ListInvites("")
+	if err != nil {
+		sendErrStatus(w, err, "cannot list invites", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, invites)
+}
+
+// listAccounts returns all accounts with space they use
+func (rest *Rest) listAccounts(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	accounts, err := rest.Store.ListAccounts()
+	if err != nil {
+		sendErrStatus(w, err, "cannot list accounts", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, accounts)
+}
+
+type roleRequest struct {
+	// Role is "admin" or empty for regular user
+	Role string `json:"role"`
+}
+
+// setRole grants or takes admin role
+func (rest *Rest) setRole(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	req := &roleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	account, err := rest.Store.SetRole(mux.Vars(r)["id"], req.Role)
+	if errors.Cause(err) == store.ErrInvalidRole {
+		sendErrStatus(w, err, "invalid role", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		sendAccountErr(w, err, "cannot set role")
+		return
+	}
+
+	sendJSON(w, account)
+}
+
+// suspendAccount returns handler, which suspends or restores account
+// admin could not suspend own account, so there is always someone to restore it
+func (rest *Rest) suspendAccount(suspended bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !rest.requireAdmin(w, r) {
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		if id == accountID(r) {
+			sendErrStatus(w, nil, "could not suspend own account", http.StatusBadRequest)
+			return
+		}
+
+		account, err := rest.Store.SetSuspended(id, suspended)
+		if err != nil {
+			sendAccountErr(w, err, "cannot suspend account")
+			return
+		}
+
+		sendJSON(w, account)
+	}
+}
+
+// forceRotateToken replaces main token of account, so leaked token stops working
+// new token is returned to admin, owner could also get it with /recover
+func (rest *Rest) forceRotateToken(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	token := newToken()
+	if err := rest.Store.RotateToken(mux.Vars(r)["id"], token); err != nil {
+		sendAccountErr(w, err, "cannot rotate token")
+		return
+	}
+
+	if _, err := w.Write([]byte(token)); err != nil {
+		log.Println(err)
+	}
+}
+
+// deleteAccount removes account together with its data and shares
+func (rest *Rest) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if id == accountID(r) {
+		sendErrStatus(w, nil, "could not delete own account", http.StatusBadRequest)
+		return
+	}
+
+	if err := rest.Store.DeleteAccount(id); err != nil {
+		sendAccountErr(w, err, "cannot delete account")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stats returns statistics of the database
+func (rest *Rest) stats(w http.ResponseWriter, r *http.Request) {
+	if !rest.requireAdmin(w, r) {
+		return
+	}
+
+	stats, err := rest.Store.Stats()
+	if err != nil {
+		sendErrStatus(w, err, "cannot read stats", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, stats)
+}
+
+// sendAccountErr reports unknown account with 404, other errors with 500
+func sendAccountErr(w http.ResponseWriter, err error, msg string) {
+	if errors.Cause(err) == store.ErrAccountNotFound {
+		sendErrStatus(w, err, "account not found", http.StatusNotFound)
+		return
+	}
+	sendErrStatus(w, err, msg, http.StatusInternalServerError)
+}
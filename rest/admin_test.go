@@ -0,0 +1,180 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhitelistAdmin(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	_, err = r.Store.CreateAccount("admin", "admin@dbfs.org", "admin token")
+	require.Nil(t, err)
+	r.Admins = "someone@dbfs.org, Admin@dbfs.org"
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	do := func(method string, url string, token string, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", token)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+	register := func(email string) string {
+		_, msg := do(http.MethodPost, "/register", "", `{"email": "`+email+`"}`)
+		return msg
+	}
+
+	tt := []struct {
+		Method string
+		URL    string
+		Token  string
+		Body   string
+		Status int
+		Result string
+	}{
+		{http.MethodGet, adminPath + "/whitelist", defaultCollection, "", http.StatusForbidden, "admin role required"},
+		{http.MethodGet, adminPath + "/whitelist", "", "", http.StatusUnauthorized, "empty Authorization header"},
+		{http.MethodPost, adminPath + "/whitelist", "admin token", `{"pattern": "smith"}`, http.StatusBadRequest, "invalid whitelist rule"},
+		{http.MethodDelete, adminPath + "/whitelist/missing", "admin token", "", http.StatusNotFound, "whitelist rule not found"},
+	}
+
+	for _, test := range tt {
+		status, msg := do(test.Method, test.URL, test.Token, test.Body)
+		assert.Equal(t, test.Status, status, test.Method+" "+test.URL)
+		assert.Equal(t, test.Result, msg)
+	}
+
+	assert.Equal(t, "current email is not whitelisted", register("trinity@zion.org"))
+
+	status, msg := do(http.MethodPost, adminPath+"/whitelist", "admin token", `{"pattern": "*@zion.org"}`)
+	require.Equal(t, http.StatusCreated, status)
+	status, msg = do(http.MethodPost, adminPath+"/whitelist", "admin token", `{"pattern": "cypher@zion.org", "deny": true}`)
+	require.Equal(t, http.StatusCreated, status)
+	deny := &store.WhitelistRule{}
+	require.Nil(t, json.Unmarshal([]byte(msg), deny))
+	assert.Equal(t, store.RuleExact, deny.Kind)
+
+	assert.Equal(t, "verification link sent. check email", register("trinity@zion.org"))
+	assert.Equal(t, "current email is not allowed to register", register("cypher@zion.org"))
+
+	status, msg = do(http.MethodGet, adminPath+"/whitelist", "admin token", "")
+	require.Equal(t, http.StatusOK, status)
+	rules := make([]*store.WhitelistRule, 0)
+	require.Nil(t, json.Unmarshal([]byte(msg), &rules))
+	assert.Len(t, rules, 4)
+
+	status, _ = do(http.MethodDelete, adminPath+"/whitelist/"+deny.ID, "admin token", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Equal(t, "verification link sent. check email", register("cypher@zion.org"))
+}
+
+func TestAccountsAdmin(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	_, err = r.Store.CreateAccount("admin", "admin@dbfs.org", "admin token")
+	require.Nil(t, err)
+	_, err = r.Store.CreateAccount("neo", "neo@zion.org", "neo token")
+	require.Nil(t, err)
+	r.Admins = "admin@dbfs.org"
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	do := func(method string, url string, token string, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", token)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+
+	tt := []struct {
+		Method string
+		URL    string
+		Token  string
+		Body   string
+		Status int
+		Result string
+	}{
+		{http.MethodGet, adminPath + "/accounts", "neo token", "", http.StatusForbidden, "admin role required"},
+		{http.MethodGet, adminPath + "/stats", "neo token", "", http.StatusForbidden, "admin role required"},
+		{http.MethodPost, adminPath + "/accounts/missing/suspend", "admin token", "", http.StatusNotFound, "account not found"},
+		{http.MethodPost, adminPath + "/accounts/admin/suspend", "admin token", "", http.StatusBadRequest, "could not suspend own account"},
+		{http.MethodDelete, adminPath + "/accounts/admin", "admin token", "", http.StatusBadRequest, "could not delete own account"},
+		{http.MethodPut, adminPath + "/accounts/neo/role", "admin token", `{"role": "god"}`, http.StatusBadRequest, "invalid role"},
+		{http.MethodPost, adminPath + "/accounts/missing/rotate", "admin token", "", http.StatusNotFound, "account not found"},
+	}
+
+	for _, test := range tt {
+		status, msg := do(test.Method, test.URL, test.Token, test.Body)
+		assert.Equal(t, test.Status, status, test.Method+" "+test.URL)
+		assert.Equal(t, test.Result, msg)
+	}
+
+	status, msg := do(http.MethodPost, basePath+"/notes.txt", "neo token", "hello")
+	require.Equal(t, http.StatusOK, status, msg)
+
+	status, msg = do(http.MethodGet, adminPath+"/accounts", "admin token", "")
+	require.Equal(t, http.StatusOK, status)
+	accounts := make([]*store.AccountInfo, 0)
+	require.Nil(t, json.Unmarshal([]byte(msg), &accounts))
+	require.Len(t, accounts, 3)
+	assert.Equal(t, "neo", accounts[2].ID)
+	assert.Equal(t, int64(5), accounts[2].Usage.Bytes)
+
+	// granted role works the same way as role from config
+	status, _ = do(http.MethodPut, adminPath+"/accounts/neo/role", "admin token", `{"role": "admin"}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = do(http.MethodGet, adminPath+"/stats", "neo token", "")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = do(http.MethodPut, adminPath+"/accounts/neo/role", "admin token", `{"role": ""}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = do(http.MethodPost, adminPath+"/accounts/neo/suspend", "admin token", "")
+	assert.Equal(t, http.StatusOK, status)
+	status, msg = do(http.MethodGet, basePath, "neo token", "")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "account suspended", msg)
+	status, _ = do(http.MethodPost, adminPath+"/accounts/neo/unsuspend", "admin token", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, token := do(http.MethodPost, adminPath+"/accounts/neo/rotate", "admin token", "")
+	require.Equal(t, http.StatusOK, status)
+	status, _ = do(http.MethodGet, basePath, "neo token", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, msg = do(http.MethodGet, basePath+"/notes.txt", token, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello", msg)
+
+	status, msg = do(http.MethodGet, adminPath+"/stats", "admin token", "")
+	require.Equal(t, http.StatusOK, status)
+	stats := &store.ServerStats{}
+	require.Nil(t, json.Unmarshal([]byte(msg), stats))
+	assert.Equal(t, 3, stats.Accounts)
+
+	status, _ = do(http.MethodDelete, adminPath+"/accounts/neo", "admin token", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = do(http.MethodGet, basePath, token, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+)
+
+// aliasRoutes maps management of share aliases and reading shares by alias
+// "/s/<alias>/<path>" works the same way as "/shared/<token>/<path>"
+func (rest *Rest) aliasRoutes(router *mux.Router) {
+	router.HandleFunc(aliasesPath, rest.listAliases).Methods("GET")
+	router.HandleFunc(aliasesPath+"/{alias}", rest.setAlias).Methods("PUT")
+	router.HandleFunc(aliasesPath+"/{alias}", rest.deleteAlias).Methods("DELETE")
+
+	// trailing slash is needed, otherwise prefix matches other routes like "/site"
+	aliasSubrouter := router.PathPrefix(aliasPath + "/").Subrouter()
+	aliasSubrouter.Use(rest.stripPrefix(aliasPath))
+	aliasSubrouter.PathPrefix("").HandlerFunc(rest.aliased).Methods("GET")
+	aliasSubrouter.PathPrefix("").HandlerFunc(rest.aliasedDrop).Methods("POST")
+}
+
+type aliasRequest struct {
+	// Token of the share alias points to
+	Token string `json:"token"`
+}
+
+// setAlias creates alias for share of the caller or re-points existing alias to other share
+func (rest *Rest) setAlias(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	req := &aliasRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		sendErrStatus(w, nil, "share token should be provided", http.StatusBadRequest)
+		return
+	}
+	if !rest.authorizeShare(w, r, collection, req.Token) {
+		return
+	}
+
+	alias, err := rest.Store.SetAlias(collection, mux.Vars(r)["alias"], req.Token)
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot set alias", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, alias)
+}
+
+// listAliases returns all aliases of the caller
+func (rest *Rest) listAliases(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	if !authorize(w, r, store.ScopeShare) {
+		return
+	}
+
+	aliases, err := rest.Store.ListAliases(collection)
+	if err != nil {
+		sendErrStatus(w, err, "cannot list aliases", http.StatusInternalServerError)
+		return
+	}
+
+	// API key sees only aliases of shares inside its prefix
+	visible := make([]*store.AliasInfo, 0, len(aliases))
+	for _, alias := range aliases {
+		if apiKey(r) != nil {
+			share, err := rest.Store.GetShare(collection, alias.Token)
+			if err != nil || !covers(r, share.Path) {
+				continue
+			}
+		}
+		visible = append(visible, alias)
+	}
+
+	sendJSON(w, visible)
+}
+
+// deleteAlias revokes alias of the caller, share stays available by its token
+func (rest *Rest) deleteAlias(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	if !authorize(w, r, store.ScopeShare) {
+		return
+	}
+	if apiKey(r) != nil {
+		token, err := rest.Store.ResolveAlias(mux.Vars(r)["alias"])
+		if err != nil {
+			sendShareErrStatus(w, err, "cannot delete alias", http.StatusInternalServerError)
+			return
+		}
+		if !rest.authorizeShare(w, r, collection, token) {
+			return
+		}
+	}
+
+	err := rest.Store.DeleteAlias(collection, mux.Vars(r)["alias"])
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot delete alias", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// aliased reads share by alias
+func (rest *Rest) aliased(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if len(keys) == 0 {
+		sendErrStatus(w, nil, "alias should be provided", http.StatusBadRequest)
+		return
+	}
+
+	token, err := rest.Store.ResolveAlias(keys[0])
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot resolve alias", http.StatusInternalServerError)
+		return
+	}
+
+	rest.sendShared(w, r, token, keys[1:])
+}
+
+// aliasedDrop uploads file into drop box share by alias
+func (rest *Rest) aliasedDrop(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if len(keys) != 2 {
+		sendErrStatus(w, nil, "alias and file name should be provided", http.StatusBadRequest)
+		return
+	}
+
+	token, err := rest.Store.ResolveAlias(keys[0])
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot resolve alias", http.StatusInternalServerError)
+		return
+	}
+
+	rest.dropShared(w, r, token, keys[1])
+}
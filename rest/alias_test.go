@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlias(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"answer"}, "answer", store.ShareOptions{}))
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"Neo"}, "neo", store.ShareOptions{}))
+	require.Nil(t, r.Store.Mkdir(defaultCollection, []string{"inbox"}))
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"inbox"}, "inbox", store.ShareOptions{Mode: store.ShareDropBox}))
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	tt := []struct {
+		Method       string
+		Path         string
+		Body         string
+		Status       int
+		ResponseBody string
+	}{
+		{http.MethodPut, aliasesPath + "/the-answer", `{"token": "answer"}`, http.StatusOK, ""},
+		{http.MethodPut, aliasesPath + "/uploads", `{"token": "inbox"}`, http.StatusOK, ""},
+		{http.MethodPut, aliasesPath + "/a", `{"token": "answer"}`, http.StatusBadRequest, "invalid alias"},
+		{http.MethodPut, aliasesPath + "/other", `{"token": "missing"}`, http.StatusNotFound, "share not found"},
+		{http.MethodGet, aliasPath + "/The-Answer", "", http.StatusOK, "42"},
+		{http.MethodGet, aliasPath + "/the-answer/answer", "", http.StatusOK, "42"},
+		{http.MethodPost, aliasPath + "/uploads/note", "hello", http.StatusCreated, "note"},
+		{http.MethodGet, aliasPath + "/missing", "", http.StatusNotFound, "alias not found"},
+		{http.MethodPut, aliasesPath + "/the-answer", `{"token": "neo"}`, http.StatusOK, ""},
+		{http.MethodGet, aliasPath + "/the-answer", "", http.StatusOK, "The One"},
+		{http.MethodDelete, aliasesPath + "/THE-ANSWER", "", http.StatusNoContent, ""},
+		{http.MethodGet, aliasPath + "/the-answer", "", http.StatusNotFound, "alias not found"},
+		{http.MethodDelete, aliasesPath + "/the-answer", "", http.StatusNotFound, "alias not found"},
+	}
+
+	for _, test := range tt {
+		req, err := http.NewRequest(test.Method, ts.URL+test.Path, strings.NewReader(test.Body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.Status, resp.StatusCode, test.Method+" "+test.Path)
+		if test.ResponseBody != "" {
+			assert.Equal(t, test.ResponseBody, string(msg), test.Method+" "+test.Path)
+		}
+	}
+
+	b, err := r.Store.Get(defaultCollection, []string{"inbox", "note"})
+	require.Nil(t, err)
+	assert.Equal(t, "hello", string(b))
+}
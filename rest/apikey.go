@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+// apiKeyRoutes maps management of additional API keys of the caller
+func (rest *Rest) apiKeyRoutes(router *mux.Router) {
+	router.HandleFunc(keysPath, rest.listAPIKeys).Methods("GET")
+	router.HandleFunc(keysPath, rest.createAPIKey).Methods("POST")
+	router.HandleFunc(keysPath+"/{id}", rest.revokeAPIKey).Methods("DELETE")
+}
+
+type apiKeyRequest struct {
+	Label  string   `json:"label"`
+	Scopes []string `json:"scopes"`
+	// Prefix is path, key is restricted to, escaped the same way as request urls
+	Prefix string `json:"prefix"`
+	// Expires is duration like "720h" or RFC3339 time, key never expires when it is empty
+	Expires string `json:"expires"`
+}
+
+type apiKeyResponse struct {
+	*store.APIKey
+	// Token is shown only once, it could not be restored later
+	Token string `json:"token"`
+}
+
+// createAPIKey adds API key to account of the caller
+func (rest *Rest) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	req := &apiKeyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	prefix, err := rest.Store.ParsePath(req.Prefix)
+	if err != nil {
+		sendErrStatus(w, err, "invalid prefix", http.StatusBadRequest)
+		return
+	}
+	expires, err := parseExpires(req.Expires)
+	if err != nil {
+		sendErrStatus(w, err, "invalid expiration time", http.StatusBadRequest)
+		return
+	}
+
+	token := randomToken(32)
+	key, err := rest.Store.CreateAPIKey(collection, token, store.APIKey{
+		ID:      randomToken(8),
+		Label:   req.Label,
+		Scopes:  req.Scopes,
+		Prefix:  prefix,
+		Expires: expires,
+	})
+	if errors.Cause(err) == store.ErrInvalidScope {
+		sendErrStatus(w, err, "invalid scopes", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		sendErrStatus(w, err, "cannot create api key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	sendJSON(w, &apiKeyResponse{APIKey: key, Token: token})
+}
+
+// listAPIKeys returns API keys of the caller without their tokens
+func (rest *Rest) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := rest.Store.ListAPIKeys(collection)
+	if err != nil {
+		sendErrStatus(w, err, "cannot list api keys", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, keys)
+}
+
+// revokeAPIKey deletes API key of the caller
+func (rest *Rest) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	err := rest.Store.RevokeAPIKey(collection, mux.Vars(r)["id"])
+	if errors.Cause(err) == store.ErrAPIKeyNotFound {
+		sendErrStatus(w, err, "api key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrStatus(w, err, "cannot revoke api key", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mainToken checks that request is authenticated with main token of account, not with API key
+// returns collection of the caller, when request could be processed
+func mainToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return "", false
+	}
+	if apiKey(r) != nil {
+		sendErrStatus(w, nil, "api keys could not manage account", http.StatusForbidden)
+		return "", false
+	}
+
+	return collection, true
+}
+
+// parseExpires reads expiration given as duration from now or RFC3339 time, empty value means no expiration
+func parseExpires(expires string) (time.Time, error) {
+	if expires == "" {
+		return time.Time{}, nil
+	}
+	if ttl, err := time.ParseDuration(expires); err == nil {
+		return time.Now().Add(ttl), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		return t, errors.Errorf("invalid expiration time \"%s\"", expires)
+	}
+	return t, nil
+}
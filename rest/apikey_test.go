@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"me"}, "inside", store.ShareOptions{}))
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"must"}, "outside", store.ShareOptions{}))
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	do := func(method string, url string, token string, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", token)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+
+	create := func(body string) *apiKeyResponse {
+		status, msg := do(http.MethodPost, keysPath, defaultCollection, body)
+		require.Equal(t, http.StatusCreated, status, msg)
+		key := &apiKeyResponse{}
+		require.Nil(t, json.Unmarshal([]byte(msg), key))
+		return key
+	}
+
+	reader := create(`{"label": "ci", "scopes": ["read", "share"], "prefix": "me"}`)
+	assert.Equal(t, "ci", reader.Label)
+	assert.Equal(t, []string{"me"}, reader.Prefix)
+	writer := create(`{"label": "writer", "scopes": ["write"], "expires": "1h"}`)
+	expired := create(`{"label": "expired", "scopes": ["read"], "expires": "2000-01-01T00:00:00Z"}`)
+
+	tt := []struct {
+		Method string
+		URL    string
+		Token  string
+		Body   string
+		Status int
+		Result string
+	}{
+		{http.MethodGet, basePath + "/me", reader.Token, "", http.StatusOK, "and\n"},
+		{http.MethodGet, basePath + "/answer", reader.Token, "", http.StatusForbidden, "path is outside of token prefix"},
+		{http.MethodGet, basePath, reader.Token, "", http.StatusForbidden, "path is outside of token prefix"},
+		{http.MethodPost, basePath + "/me/new", reader.Token, "data", http.StatusForbidden, "token does not allow write"},
+		{http.MethodDelete, basePath + "/me/and", reader.Token, "", http.StatusForbidden, "token does not allow delete"},
+		{http.MethodGet, sharesPath + "/outside", reader.Token, "", http.StatusNotFound, "share not found"},
+		{http.MethodDelete, sharesPath + "/outside", reader.Token, "", http.StatusNotFound, "share not found"},
+		{http.MethodGet, basePath + "/answer", writer.Token, "", http.StatusForbidden, "token does not allow read"},
+		{http.MethodGet, basePath, expired.Token, "", http.StatusUnauthorized, "token expired"},
+		{http.MethodGet, keysPath, reader.Token, "", http.StatusForbidden, "api keys could not manage account"},
+		{http.MethodPost, keysPath, defaultCollection, `{"scopes": ["everything"]}`, http.StatusBadRequest, "invalid scopes"},
+		{http.MethodDelete, keysPath + "/missing", defaultCollection, "", http.StatusNotFound, "api key not found"},
+	}
+
+	for _, test := range tt {
+		status, msg := do(test.Method, test.URL, test.Token, test.Body)
+		assert.Equal(t, test.Status, status, test.Method+" "+test.URL)
+		assert.Equal(t, test.Result, msg, test.Method+" "+test.URL)
+	}
+
+	// writer could write anywhere
+	status, _ := do(http.MethodPost, basePath+"/answer", writer.Token, "43")
+	assert.Equal(t, http.StatusOK, status)
+
+	// shares outside of prefix are hidden
+	status, msg := do(http.MethodGet, sharesPath, reader.Token, "")
+	require.Equal(t, http.StatusOK, status)
+	shares := make([]*store.ShareInfo, 0)
+	require.Nil(t, json.Unmarshal([]byte(msg), &shares))
+	require.Len(t, shares, 1)
+	assert.Equal(t, "inside", shares[0].Token)
+
+	// list does not expose tokens
+	status, msg = do(http.MethodGet, keysPath, defaultCollection, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, msg, reader.Token)
+	keys := make([]*store.APIKey, 0)
+	require.Nil(t, json.Unmarshal([]byte(msg), &keys))
+	assert.Len(t, keys, 3)
+
+	status, _ = do(http.MethodDelete, keysPath+"/"+reader.ID, defaultCollection, "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, msg = do(http.MethodGet, basePath+"/me", reader.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid token", msg)
+}
@@ -0,0 +1,307 @@
+package rest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+// Authenticator resolves credentials of request to principal
+// nil principal without error means that request has no credentials of this kind, so next authenticator is tried
+// error means that credentials are present, but could not be accepted, request is rejected then
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc lets to use function as Authenticator
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (fn AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return fn(r)
+}
+
+// Principal is account request acts for
+type Principal struct {
+	Account *store.Account
+	// Key limits scopes and path of request, nil means full access
+	Key *store.APIKey
+	// Session is ID of session request was authenticated with, empty for other credentials
+	Session string
+
+	// presigned is signature of presigned url request was authenticated with, empty for other credentials
+	presigned string
+}
+
+// names of built-in authenticators used in configuration
+const (
+	AuthToken  = "token"
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthProxy  = "proxy"
+)
+
+// DefaultAuthenticators is chain used when Rest.Authenticators is not set
+var DefaultAuthenticators = []string{AuthToken, AuthBearer, AuthBasic}
+
+// NewAuthenticators builds chain of built-in authenticators from comma-separated names
+// proxy authenticator reads email from proxyHeader of requests coming from trustedProxies (comma-separated IPs or CIDRs)
+func NewAuthenticators(names string, s *store.Store, proxyHeader string, trustedProxies string) ([]Authenticator, error) {
+	chain := make([]Authenticator, 0)
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case AuthToken:
+			chain = append(chain, &TokenAuthenticator{Store: s})
+		case AuthBearer:
+			chain = append(chain, &BearerAuthenticator{Store: s})
+		case AuthBasic:
+			chain = append(chain, &BasicAuthenticator{Store: s})
+		case AuthProxy:
+			if proxyHeader == "" {
+				return nil, errors.New("proxy authentication requires header name")
+			}
+			trusted, err := parseNetworks(trustedProxies)
+			if err != nil {
+				return nil, err
+			}
+			if len(trusted) == 0 {
+				return nil, errors.New("proxy authentication requires trusted proxies")
+			}
+			chain = append(chain, &ProxyAuthenticator{Store: s, Header: proxyHeader, Trusted: trusted})
+		default:
+			return nil, errors.Errorf("unknown authenticator \"%s\"", name)
+		}
+	}
+
+	return chain, nil
+}
+
+// TokenAuthenticator takes the whole Authorization header as token, the way dbfs always worked
+// headers with Bearer and Basic schemes are left to their authenticators
+type TokenAuthenticator struct {
+	Store *store.Store
+}
+
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" || hasScheme(header, "Bearer") || hasScheme(header, "Basic") {
+		return nil, nil
+	}
+
+	return tokenPrincipal(a.Store, header)
+}
+
+// BearerAuthenticator takes token from "Authorization: Bearer <token>" header
+type BearerAuthenticator struct {
+	Store *store.Store
+}
+
+func (a *BearerAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if !hasScheme(header, "Bearer") {
+		return nil, nil
+	}
+
+	return tokenPrincipal(a.Store, strings.TrimSpace(header[len("Bearer "):]))
+}
+
+// BasicAuthenticator takes token from password of HTTP Basic authentication, user name is ignored
+// it lets to use tools, which know only user and password
+type BasicAuthenticator struct {
+	Store *store.Store
+}
+
+func (a *BasicAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if !hasScheme(r.Header.Get("Authorization"), "Basic") {
+		return nil, nil
+	}
+
+	_, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, store.ErrInvalidToken
+	}
+	return tokenPrincipal(a.Store, password)
+}
+
+// ProxyAuthenticator trusts email in header set by reverse proxy, which authenticated user itself
+// header is taken only from trusted proxies, proxy should remove it from incoming requests
+type ProxyAuthenticator struct {
+	Store  *store.Store
+	Header string
+	// Trusted lists networks of proxies, header of other peers is ignored
+	Trusted []*net.IPNet
+}
+
+func (a *ProxyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	email := r.Header.Get(a.Header)
+	if email == "" || !a.trusted(r.RemoteAddr) {
+		return nil, nil
+	}
+
+	account, err := a.Store.AccountByEmail(email)
+	if errors.Cause(err) == store.ErrAccountNotFound {
+		return nil, &authError{status: http.StatusUnauthorized, msg: "unknown account", err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.Suspended {
+		return nil, store.ErrAccountSuspended
+	}
+
+	return &Principal{Account: account}, nil
+}
+
+// trusted checks if request comes from trusted proxy
+func (a *ProxyAuthenticator) trusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range a.Trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// authError is returned by authenticators, which need own status and message
+type authError struct {
+	status int
+	msg    string
+	err    error
+	// cookie is sent together with error, it is used for removing stale session
+	cookie *http.Cookie
+}
+
+func (e *authError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return e.msg
+}
+
+// authenticate is middleware, which resolves credentials to principal and keeps it in request context
+// configured authenticators go first, then presigned urls and session cookies, so scripts are not affected by browser sessions
+// requests without credentials are passed as is, so public routes keep working, handlers decide if account is required
+func (rest *Rest) authenticate(next http.Handler) http.Handler {
+	chain := rest.Authenticators
+	if chain == nil {
+		chain, _ = NewAuthenticators(strings.Join(DefaultAuthenticators, ","), rest.Store, "", "")
+	}
+	chain = append(chain[:len(chain):len(chain)], AuthenticatorFunc(rest.presignedPrincipal), AuthenticatorFunc(rest.sessionPrincipal))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, authenticator := range chain {
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				sendAuthErr(w, err)
+				return
+			}
+			if principal != nil {
+				r = r.WithContext(context.WithValue(r.Context(), principalKey, principal))
+				if principal.presigned != "" {
+					rest.servePresigned(next, w, r, principal.presigned)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if r.Header.Get("Authorization") != "" {
+			sendErrStatus(w, nil, "unsupported authorization scheme", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contextKey is type of request context keys set by this package
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+// principal returns principal resolved by authenticate, nil is returned for anonymous request
+func principal(r *http.Request) *Principal {
+	principal, _ := r.Context().Value(principalKey).(*Principal)
+	return principal
+}
+
+// tokenPrincipal resolves main token or API key
+func tokenPrincipal(s *store.Store, token string) (*Principal, error) {
+	account, key, err := s.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{Account: account, Key: key}, nil
+}
+
+// sendAuthErr reports rejected credentials
+func sendAuthErr(w http.ResponseWriter, err error) {
+	if e, ok := err.(*authError); ok {
+		if e.cookie != nil {
+			http.SetCookie(w, e.cookie)
+		}
+		sendErrStatus(w, e.err, e.msg, e.status)
+		return
+	}
+
+	switch errors.Cause(err) {
+	case store.ErrInvalidToken:
+		sendErrStatus(w, err, "invalid token", http.StatusUnauthorized)
+	case store.ErrTokenExpired:
+		sendErrStatus(w, err, "token expired", http.StatusUnauthorized)
+	case store.ErrAccountSuspended:
+		sendErrStatus(w, err, "account suspended", http.StatusForbidden)
+	default:
+		sendErrStatus(w, err, "cannot authenticate", http.StatusInternalServerError)
+	}
+}
+
+// hasScheme checks if Authorization header uses given scheme, scheme is case-insensitive
+func hasScheme(header string, scheme string) bool {
+	return len(header) > len(scheme) && strings.EqualFold(header[:len(scheme)], scheme) && header[len(scheme)] == ' '
+}
+
+// parseNetworks parses comma-separated IPs and CIDRs, single IP is network of one address
+func parseNetworks(list string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, errors.Errorf("invalid proxy address \"%s\"", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid proxy network \"%s\"", item)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
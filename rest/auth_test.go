@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticators(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	proxied, err := r.Store.CreateAccount("proxied", "proxy@gmail.com", "proxytoken")
+	require.Nil(t, err)
+	require.Nil(t, r.Store.Put(proxied.ID, []string{"proxied.txt"}, strings.NewReader("data")))
+
+	r.Authenticators, err = NewAuthenticators("token, bearer,basic,proxy", r.Store, "X-Forwarded-Email", "10.0.0.0/8,127.0.0.1")
+	require.Nil(t, err)
+	router := r.Router()
+
+	tt := []struct {
+		Name       string
+		Header     string
+		Value      string
+		RemoteAddr string
+		Status     int
+		Body       string
+	}{
+		{"raw token", "Authorization", defaultCollection, "", http.StatusOK, "answer"},
+		{"bearer", "Authorization", "Bearer " + defaultCollection, "", http.StatusOK, "answer"},
+		{"bearer lowercase", "Authorization", "bearer " + defaultCollection, "", http.StatusOK, "answer"},
+		{"bearer invalid", "Authorization", "Bearer wrong", "", http.StatusUnauthorized, "invalid token"},
+		{"basic", "Authorization", "Basic " + basicAuth("anyone", defaultCollection), "", http.StatusOK, "answer"},
+		{"basic invalid", "Authorization", "Basic " + basicAuth("anyone", "wrong"), "", http.StatusUnauthorized, "invalid token"},
+		{"basic malformed", "Authorization", "Basic !!!", "", http.StatusUnauthorized, "invalid token"},
+		{"raw invalid", "Authorization", "wrong", "", http.StatusUnauthorized, "invalid token"},
+		{"trusted proxy", "X-Forwarded-Email", "Proxy@gmail.com", "10.1.2.3:4000", http.StatusOK, "proxied.txt"},
+		{"trusted proxy single ip", "X-Forwarded-Email", "proxy@gmail.com", "127.0.0.1:4000", http.StatusOK, "proxied.txt"},
+		{"trusted proxy unknown email", "X-Forwarded-Email", "other@gmail.com", "10.1.2.3:4000", http.StatusUnauthorized, "unknown account"},
+		{"untrusted proxy", "X-Forwarded-Email", "proxy@gmail.com", "192.168.1.1:4000", http.StatusOK, "empty Authorization header"},
+	}
+
+	for _, test := range tt {
+		req := httptest.NewRequest(http.MethodGet, basePath, nil)
+		req.Header.Set(test.Header, test.Value)
+		if test.RemoteAddr != "" {
+			req.RemoteAddr = test.RemoteAddr
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, test.Status, w.Code, test.Name)
+		assert.Contains(t, w.Body.String(), test.Body, test.Name)
+	}
+
+	// chain without raw token does not guess scheme of header
+	r.Authenticators, err = NewAuthenticators("bearer", r.Store, "", "")
+	require.Nil(t, err)
+	router = r.Router()
+
+	req := httptest.NewRequest(http.MethodGet, basePath, nil)
+	req.Header.Set("Authorization", defaultCollection)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "unsupported authorization scheme")
+
+	// suspended account is rejected by every authenticator
+	_, err = r.Store.SetSuspended(defaultCollection, true)
+	require.Nil(t, err)
+	req = httptest.NewRequest(http.MethodGet, basePath, nil)
+	req.Header.Set("Authorization", "Bearer "+defaultCollection)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "account suspended")
+}
+
+func TestNewAuthenticators(t *testing.T) {
+	tt := []struct {
+		Names   string
+		Header  string
+		Proxies string
+		Len     int
+		Err     bool
+	}{
+		{"token,bearer,basic", "", "", 3, false},
+		{"", "", "", 0, false},
+		{"bearer,digest", "", "", 0, true},
+		{"proxy", "", "10.0.0.1", 0, true},
+		{"proxy", "X-Forwarded-Email", "", 0, true},
+		{"proxy", "X-Forwarded-Email", "10.0.0.300", 0, true},
+		{"proxy", "X-Forwarded-Email", "10.0.0.1, ::1, 172.16.0.0/12", 1, false},
+	}
+
+	for _, test := range tt {
+		chain, err := NewAuthenticators(test.Names, nil, test.Header, test.Proxies)
+		if test.Err {
+			assert.NotNil(t, err, test.Names)
+			continue
+		}
+		require.Nil(t, err, test.Names)
+		assert.Len(t, chain, test.Len, test.Names)
+	}
+
+	chain, err := NewAuthenticators("proxy", nil, "X-Forwarded-Email", "10.0.0.1,::1")
+	require.Nil(t, err)
+	proxy := chain[0].(*ProxyAuthenticator)
+	assert.True(t, proxy.trusted("10.0.0.1:80"))
+	assert.True(t, proxy.trusted("[::1]:80"))
+	assert.False(t, proxy.trusted("10.0.0.2:80"))
+	assert.False(t, proxy.trusted("unix"))
+}
+
+func basicAuth(user string, password string) string {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth(user, password)
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Basic ")
+}
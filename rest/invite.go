@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+// inviteRoutes maps invite codes of the caller
+func (rest *Rest) inviteRoutes(router *mux.Router) {
+	router.HandleFunc(invitesPath, rest.listInvites).Methods("GET")
+	router.HandleFunc(invitesPath, rest.createInvite).Methods("POST")
+	router.HandleFunc(invitesPath+"/{code}", rest.revokeInvite).Methods("DELETE")
+}
+
+type inviteRequest struct {
+	// MaxUses is number of registrations invite could be used for, 1 when not set
+	MaxUses int `json:"max_uses"`
+	// Expires is duration like "72h" or RFC3339 time, invite never expires when it is empty
+	Expires string `json:"expires"`
+}
+
+// createInvite mints invite code of the caller
+// registrations by invites of one user are limited by InviteAllowance, admins are not limited
+func (rest *Rest) createInvite(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	req := &inviteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.MaxUses < 0 {
+		sendErrStatus(w, nil, "invalid number of uses", http.StatusBadRequest)
+		return
+	}
+	expires, err := parseExpires(req.Expires)
+	if err != nil {
+		sendErrStatus(w, err, "invalid expiration time", http.StatusBadRequest)
+		return
+	}
+
+	allowance := rest.InviteAllowance
+	if rest.isAdmin(account(r)) {
+		allowance = -1
+	}
+
+	invite, err := rest.Store.CreateInvite(collection, randomToken(12), req.MaxUses, expires, allowance)
+	if errors.Cause(err) == store.ErrInviteAllowance {
+		sendErrStatus(w, err, "invite allowance exceeded", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		sendErrStatus(w, err, "cannot create invite", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	sendJSON(w, invite)
+}
+
+// listInvites returns invites of the caller together with registrations made with them
+func (rest *Rest) listInvites(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	invites, err := rest.Store.ListInvites(collection)
+	if err != nil {
+		sendErrStatus(w, err, "cannot list invites", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, invites)
+}
+
+// revokeInvite stops invite of the caller from being used
+func (rest *Rest) revokeInvite(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	err := rest.Store.RevokeInvite(collection, mux.Vars(r)["code"])
+	if err != nil {
+		sendInviteErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendInviteErr maps store invite errors to status codes
+func sendInviteErr(w http.ResponseWriter, err error) {
+	switch errors.Cause(err) {
+	case store.ErrInviteNotFound:
+		sendErrStatus(w, err, "invite not found", http.StatusNotFound)
+	case store.ErrInviteExpired:
+		sendErrStatus(w, err, "invite expired", http.StatusGone)
+	case store.ErrInviteExhausted:
+		sendErrStatus(w, err, "invite has no uses left", http.StatusGone)
+	default:
+		sendErrStatus(w, err, "cannot use invite", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvite(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	mailer := &recordingEmail{sent: make(map[string]string)}
+	r.Email = mailer
+	r.InviteAllowance = 2
+	r.Admins = "admin@dbfs.org"
+	_, err = r.Store.CreateAccount("admin", "admin@dbfs.org", "admin token")
+	require.Nil(t, err)
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	do := func(method string, url string, token string, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", token)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+	create := func(token string, body string) *store.Invite {
+		status, msg := do(http.MethodPost, invitesPath, token, body)
+		require.Equal(t, http.StatusCreated, status, msg)
+		invite := &store.Invite{}
+		require.Nil(t, json.Unmarshal([]byte(msg), invite))
+		return invite
+	}
+	register := func(email string, invite string) (int, string) {
+		return do(http.MethodPost, "/register", "", `{"email": "`+email+`", "invite": "`+invite+`"}`)
+	}
+
+	token := registerAccount(t, ts, mailer, "random@gmail.com")
+	account, _, err := r.Store.Authenticate(token)
+	require.Nil(t, err)
+
+	invite := create(token, `{"max_uses": 2, "expires": "24h"}`)
+	assert.Equal(t, 2, invite.MaxUses)
+	assert.Equal(t, account.ID, invite.Owner)
+
+	status, msg := do(http.MethodPost, invitesPath, token, `{}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "invite allowance exceeded", msg)
+	// admins are not limited
+	create("admin token", `{"max_uses": 5}`)
+
+	status, msg = do(http.MethodPost, keysPath, token, `{"scopes": ["read"]}`)
+	require.Equal(t, http.StatusCreated, status, msg)
+	key := &apiKeyResponse{}
+	require.Nil(t, json.Unmarshal([]byte(msg), key))
+
+	tt := []struct {
+		Method string
+		URL    string
+		Token  string
+		Body   string
+		Status int
+		Result string
+	}{
+		{http.MethodPost, invitesPath, "", `{}`, http.StatusUnauthorized, "empty Authorization header"},
+		{http.MethodPost, invitesPath, key.Token, `{}`, http.StatusForbidden, "api keys could not manage account"},
+		{http.MethodPost, invitesPath, "admin token", `{"max_uses": -1}`, http.StatusBadRequest, "invalid number of uses"},
+		{http.MethodPost, invitesPath, "admin token", `{"expires": "soon"}`, http.StatusBadRequest, "invalid expiration time"},
+		{http.MethodDelete, invitesPath + "/" + invite.Code, "admin token", "", http.StatusNotFound, "invite not found"},
+		{http.MethodGet, adminPath + invitesPath, token, "", http.StatusForbidden, "admin role required"},
+	}
+
+	for _, test := range tt {
+		status, msg := do(test.Method, test.URL, test.Token, test.Body)
+		assert.Equal(t, test.Status, status, test.Method+" "+test.URL)
+		assert.Equal(t, test.Result, msg)
+	}
+
+	_, msg = register("neo@matrix.com", "")
+	assert.Equal(t, "current email is not whitelisted", msg)
+	status, msg = register("neo@matrix.com", "wrong")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "invite not found", msg)
+
+	// invite does not override deny rule
+	_, err = r.Store.AddWhitelistRule(store.WhitelistRule{ID: "deny", Pattern: "cypher@matrix.com", Deny: true})
+	require.Nil(t, err)
+	status, msg = register("cypher@matrix.com", invite.Code)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "current email is not allowed to register", msg)
+	assert.Empty(t, mailer.sent["cypher@matrix.com"])
+
+	status, msg = register("neo@matrix.com", invite.Code)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "verification link sent. check email", msg)
+	link := verifyLink(t, mailer.sent["neo@matrix.com"])
+	status, _ = do(http.MethodGet, link[strings.Index(link, verifyPath+"/"):], "", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	neo, _, err := r.Store.Authenticate(mailer.sent["neo@matrix.com"])
+	require.Nil(t, err)
+	assert.Equal(t, account.ID, neo.InvitedBy)
+
+	status, msg = do(http.MethodGet, invitesPath, token, "")
+	require.Equal(t, http.StatusOK, status)
+	invites := make([]*store.Invite, 0)
+	require.Nil(t, json.Unmarshal([]byte(msg), &invites))
+	require.Len(t, invites, 1)
+	require.Len(t, invites[0].Uses, 1)
+	assert.Equal(t, "neo@matrix.com", invites[0].Uses[0].Email)
+
+	status, _ = do(http.MethodDelete, invitesPath+"/"+invite.Code, token, "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = register("trinity@matrix.com", invite.Code)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, msg = do(http.MethodGet, adminPath+invitesPath, "admin token", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Nil(t, json.Unmarshal([]byte(msg), &invites))
+	assert.Len(t, invites, 2)
+}
@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+)
+
+// mountRoutes maps attaching shares of other users to caller's tree
+// mounts are detached with DELETE request to mount point under /db
+func (rest *Rest) mountRoutes(router *mux.Router) {
+	router.HandleFunc(mountsPath, rest.listMounts).Methods("GET")
+	router.HandleFunc(mountsPath, rest.mount).Methods("POST")
+}
+
+type mountRequest struct {
+	// Token of the share to mount
+	Token string `json:"token"`
+	// Path is mount point, escaped the same way as request urls
+	Path string `json:"path"`
+}
+
+// mount attaches share at given path of caller's collection
+// password of protected share is taken from X-Share-Password header
+func (rest *Rest) mount(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	req := &mountRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		sendErrStatus(w, nil, "share token should be provided", http.StatusBadRequest)
+		return
+	}
+	keys, err := rest.Store.ParsePath(req.Path)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if len(keys) == 0 {
+		sendErrStatus(w, nil, "mount path should be provided", http.StatusBadRequest)
+		return
+	}
+	if !authorizePath(w, r, store.ScopeWrite, keys) {
+		return
+	}
+
+	mount, err := rest.Store.Mount(collection, keys, req.Token, r.Header.Get(sharePasswordHeader))
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot mount share", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	sendJSON(w, mount)
+}
+
+// listMounts returns all mounts of the caller
+func (rest *Rest) listMounts(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	if !authorize(w, r, store.ScopeRead) {
+		return
+	}
+
+	mounts, err := rest.Store.ListMounts(collection)
+	if err != nil {
+		sendErrStatus(w, err, "cannot list mounts", http.StatusInternalServerError)
+		return
+	}
+
+	// API key sees only mounts inside its prefix
+	visible := make([]*store.MountInfo, 0, len(mounts))
+	for _, mount := range mounts {
+		if covers(r, mount.Path) {
+			visible = append(visible, mount)
+		}
+	}
+
+	sendJSON(w, visible)
+}
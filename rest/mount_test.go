@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMount(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	require.Nil(t, r.Store.Create("friend"))
+	require.Nil(t, r.Store.Put("friend", []string{"present"}, strings.NewReader("socks")))
+	require.Nil(t, r.Store.Share("friend", []string{}, "gift", store.ShareOptions{}))
+	require.Nil(t, r.Store.Share("friend", []string{"present"}, "present", store.ShareOptions{}))
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	tt := []struct {
+		Method       string
+		Path         string
+		Body         string
+		Status       int
+		ResponseBody string
+	}{
+		{http.MethodPost, mountsPath, `{"token": "missing", "path": "gifts"}`, http.StatusNotFound, "share not found"},
+		{http.MethodPost, mountsPath, `{"token": "present", "path": ""}`, http.StatusBadRequest, "mount path should be provided"},
+		{http.MethodPost, mountsPath, `{"token": "present", "path": "answer"}`, http.StatusBadRequest, "cannot mount share"},
+		{http.MethodPost, mountsPath, `{"token": "present", "path": "gifts/present"}`, http.StatusCreated, ""},
+		{http.MethodGet, basePath + "/gifts/present", "", http.StatusOK, "socks"},
+		{http.MethodPost, basePath + "/gifts/present", "new", http.StatusForbidden, "mounted share is read-only"},
+		{http.MethodDelete, basePath + "/gifts/present/inner", "", http.StatusForbidden, "mounted share is read-only"},
+		{http.MethodPost, mountsPath, `{"token": "gift", "path": "friend"}`, http.StatusCreated, ""},
+		{http.MethodGet, basePath + "/friend/present", "", http.StatusOK, "socks"},
+	}
+
+	for _, test := range tt {
+		req, err := http.NewRequest(test.Method, ts.URL+test.Path, strings.NewReader(test.Body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.Status, resp.StatusCode, test.Path)
+		if test.Status != http.StatusCreated {
+			assert.Equal(t, test.ResponseBody, string(msg))
+		}
+	}
+
+	// list mounts
+	req, err := http.NewRequest(http.MethodGet, ts.URL+mountsPath, nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	mounts := make([]*store.MountInfo, 0)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&mounts))
+	require.Len(t, mounts, 2)
+	assert.Equal(t, []string{"friend"}, mounts[0].Path)
+	assert.Equal(t, "gift", mounts[0].Token)
+
+	// revoked share
+	require.Nil(t, r.Store.RevokeShare("friend", "present"))
+	req, err = http.NewRequest(http.MethodGet, ts.URL+basePath+"/gifts/present", nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+
+	// delete detaches mount, shared data stays
+	req, err = http.NewRequest(http.MethodDelete, ts.URL+basePath+"/friend", nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	b, err := r.Store.Get("friend", []string{"present"})
+	require.Nil(t, err)
+	assert.Equal(t, "socks", string(b))
+	mounts, err = r.Store.ListMounts(defaultCollection)
+	require.Nil(t, err)
+	require.Len(t, mounts, 1)
+}
@@ -0,0 +1,263 @@
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPresignTTL = time.Hour
+	// maxPresignTTL limits lifetime of presigned url, because it could not be revoked
+	maxPresignTTL = 7 * 24 * time.Hour
+)
+
+// query parameters of presigned url, other parameters are not allowed, so url could not change operation
+const (
+	presignAccount   = "account"
+	presignExpires   = "expires"
+	presignMaxSize   = "max-size"
+	presignSignature = "signature"
+)
+
+// presignRoutes maps creation of presigned urls, urls themselves are handled by /db routes
+func (rest *Rest) presignRoutes(router *mux.Router) {
+	router.HandleFunc(presignPath, rest.presign).Methods("POST")
+}
+
+type presignRequest struct {
+	// Method is GET for download or POST for upload
+	Method string `json:"method"`
+	// Path is escaped the same way as request urls
+	Path string `json:"path"`
+	// Expires is duration like "15m" or RFC3339 time, defaultPresignTTL is used when empty
+	Expires string `json:"expires"`
+	// MaxSize limits uploaded body in bytes, 0 means no limit
+	MaxSize int64 `json:"max_size"`
+}
+
+type presignResponse struct {
+	URL     string    `json:"url"`
+	Method  string    `json:"method"`
+	Expires time.Time `json:"expires"`
+}
+
+// presign returns url, which allows single kind of operation on single path without token
+// API key could presign only operations it is allowed to do itself
+func (rest *Rest) presign(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	req := &presignRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	scope := store.ScopeRead
+	switch req.Method {
+	case http.MethodGet:
+		if req.MaxSize != 0 {
+			sendErrStatus(w, nil, "max size is allowed only for POST", http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		scope = store.ScopeWrite
+	default:
+		sendErrStatus(w, nil, "method should be GET or POST", http.StatusBadRequest)
+		return
+	}
+	if req.MaxSize < 0 {
+		sendErrStatus(w, nil, "invalid max size", http.StatusBadRequest)
+		return
+	}
+
+	keys, err := rest.Store.ParsePath(req.Path)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if req.Method == http.MethodPost && len(keys) == 0 {
+		sendErrStatus(w, nil, "file name is not provided", http.StatusBadRequest)
+		return
+	}
+	if !authorizePath(w, r, scope, keys) {
+		return
+	}
+
+	expires, err := parseExpires(req.Expires)
+	if err != nil {
+		sendErrStatus(w, err, "invalid expiration time", http.StatusBadRequest)
+		return
+	}
+	key := apiKey(r)
+	if expires.IsZero() {
+		expires = time.Now().Add(defaultPresignTTL)
+		// default lifetime is shortened to lifetime of API key
+		if key != nil && !key.Expires.IsZero() && expires.After(key.Expires) {
+			expires = key.Expires
+		}
+	}
+	if expires.Before(time.Now()) || expires.After(time.Now().Add(maxPresignTTL)) {
+		sendErrStatus(w, nil, "expiration time should be within "+maxPresignTTL.String(), http.StatusBadRequest)
+		return
+	}
+	if key != nil && !key.Expires.IsZero() && expires.After(key.Expires) {
+		sendErrStatus(w, nil, "url could not outlive api key", http.StatusBadRequest)
+		return
+	}
+
+	names := make([]string, len(keys))
+	for i, name := range keys {
+		names[i] = url.PathEscape(name)
+	}
+	path := basePath + "/" + strings.Join(names, "/")
+
+	query := url.Values{}
+	query.Set(presignAccount, collection)
+	query.Set(presignExpires, strconv.FormatInt(expires.Unix(), 10))
+	if req.MaxSize > 0 {
+		query.Set(presignMaxSize, strconv.FormatInt(req.MaxSize, 10))
+	}
+	query.Set(presignSignature, rest.sign(presignPayload(req.Method, path, query)))
+
+	sendJSON(w, &presignResponse{
+		URL:     rest.publicURL(r) + path + "?" + query.Encode(),
+		Method:  req.Method,
+		Expires: time.Unix(expires.Unix(), 0),
+	})
+}
+
+// presignedPrincipal checks signature of presigned url and acts as its account, url works only once
+// request is limited to signed method and path the same way as API key is limited to its scope and prefix
+// url without signature or request with Authorization header is left to other authenticators
+func (rest *Rest) presignedPrincipal(r *http.Request) (*Principal, error) {
+	query := r.URL.Query()
+	if query.Get(presignSignature) == "" || r.Header.Get("Authorization") != "" {
+		return nil, nil
+	}
+
+	invalid := &authError{status: http.StatusForbidden, msg: "invalid signature"}
+	for name := range query {
+		if name != presignAccount && name != presignExpires && name != presignMaxSize && name != presignSignature {
+			return nil, invalid
+		}
+	}
+
+	path := r.URL.EscapedPath()
+	signature := query.Get(presignSignature)
+	query.Del(presignSignature)
+	if !strings.HasPrefix(path, basePath+"/") || !rest.verifySignature(presignPayload(r.Method, path, query), signature) {
+		return nil, invalid
+	}
+
+	expires, err := strconv.ParseInt(query.Get(presignExpires), 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return nil, &authError{status: http.StatusForbidden, msg: "url expired", err: err}
+	}
+
+	keys, err := rest.Store.ParsePath(strings.TrimPrefix(path, basePath))
+	if err != nil {
+		return nil, &authError{status: http.StatusBadRequest, msg: "invalid path", err: err}
+	}
+
+	account, err := rest.Store.GetAccount(query.Get(presignAccount))
+	if errors.Cause(err) == store.ErrAccountNotFound {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.Suspended {
+		return nil, store.ErrAccountSuspended
+	}
+
+	if maxSize := query.Get(presignMaxSize); maxSize != "" {
+		limit, err := strconv.ParseInt(maxSize, 10, 64)
+		if err != nil {
+			return nil, invalid
+		}
+		if r.ContentLength > limit {
+			return nil, &authError{status: http.StatusRequestEntityTooLarge, msg: "file exceeds max size"}
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, limit)
+	}
+
+	// url is reserved only after all checks passed, so rejected requests do not burn it
+	// reservation is released by servePresigned, when handler fails
+	err = rest.Store.UsePresigned(signature, time.Unix(expires, 0))
+	if errors.Cause(err) == store.ErrPresignUsed {
+		return nil, &authError{status: http.StatusForbidden, msg: "url already used", err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scope := store.ScopeRead
+	if r.Method == http.MethodPost {
+		scope = store.ScopeWrite
+	}
+	key := &store.APIKey{ID: "presigned", Label: "presigned url", Scopes: []string{scope}, Prefix: keys}
+
+	return &Principal{Account: account, Key: key, presigned: signature}, nil
+}
+
+// servePresigned runs handler for request authenticated by presigned url
+// url was reserved before, so concurrent requests could not use it twice,
+// it is released again when handler fails (missing file, folder at upload path, too large body), so the url could be retried
+func (rest *Rest) servePresigned(next http.Handler, w http.ResponseWriter, r *http.Request, signature string) {
+	sw := &statusWriter{ResponseWriter: w}
+	next.ServeHTTP(sw, r)
+	if !sw.failed && (sw.status == 0 || sw.status >= 200 && sw.status < 300) {
+		return
+	}
+
+	if err := rest.Store.ReleasePresigned(signature); err != nil {
+		log.Printf("[ERROR] %s", errors.Wrap(err, "cannot release presigned url"))
+	}
+}
+
+// statusWriter remembers status code of response, status is 0 until handler writes anything
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	// failed is set by sendErr, because some handlers report errors with 200 status
+	failed bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// markFailed tells statusWriter that handler failed, other writers are left as is
+func markFailed(w http.ResponseWriter) {
+	if sw, ok := w.(*statusWriter); ok {
+		sw.failed = true
+	}
+}
+
+// presignPayload returns signed part of presigned url, query is encoded with sorted keys
+func presignPayload(method string, path string, query url.Values) string {
+	return "presign:" + method + "\n" + path + "\n" + query.Encode()
+}
@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresign(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+	r.PublicURL = ts.URL
+
+	do := func(method string, url string, token string, body string) (int, string) {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		require.Nil(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+	presign := func(token string, body string) string {
+		status, msg := do(http.MethodPost, ts.URL+presignPath, token, body)
+		require.Equal(t, http.StatusOK, status, msg)
+		result := &presignResponse{}
+		require.Nil(t, json.Unmarshal([]byte(msg), result))
+		return result.URL
+	}
+
+	status, _ := do(http.MethodPost, ts.URL+basePath+"/docs/q3%20report.txt", defaultCollection, "report")
+	require.Equal(t, http.StatusOK, status)
+
+	download := presign(defaultCollection, `{"method": "GET", "path": "docs/q3%20report.txt", "expires": "10m"}`)
+	assert.True(t, strings.HasPrefix(download, ts.URL+basePath+"/docs/q3%20report.txt?"))
+	upload := presign(defaultCollection, `{"method": "POST", "path": "inbox/data.txt", "max_size": 5}`)
+	folder := presign(defaultCollection, `{"method": "GET", "path": "docs"}`)
+
+	key := &apiKeyResponse{}
+	_, msg := do(http.MethodPost, ts.URL+keysPath, defaultCollection, `{"scopes": ["read"], "prefix": "docs", "expires": "1h"}`)
+	require.Nil(t, json.Unmarshal([]byte(msg), key))
+
+	// url with signature made for other request
+	parsed, err := url.Parse(download)
+	require.Nil(t, err)
+	query := parsed.Query()
+	query.Set(presignExpires, strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+	query.Del(presignSignature)
+	query.Set(presignSignature, r.sign(presignPayload(http.MethodGet, parsed.EscapedPath(), query)))
+	expired := ts.URL + parsed.EscapedPath() + "?" + query.Encode()
+
+	tt := []struct {
+		Method string
+		URL    string
+		Token  string
+		Body   string
+		Status int
+		Result string
+	}{
+		{http.MethodGet, download, "", "", http.StatusOK, "report"},
+		{http.MethodGet, folder, "", "", http.StatusOK, "q3 report.txt\n"},
+		{http.MethodPost, download, "", "new", http.StatusForbidden, "invalid signature"},
+		{http.MethodGet, strings.Replace(download, "q3%20report", "other", 1), "", "", http.StatusForbidden, "invalid signature"},
+		{http.MethodGet, download + "&append=1", "", "", http.StatusForbidden, "invalid signature"},
+		{http.MethodGet, strings.Replace(download, "signature=", "signature=0", 1), "", "", http.StatusForbidden, "invalid signature"},
+		{http.MethodGet, expired, "", "", http.StatusForbidden, "url expired"},
+		{http.MethodPost, upload, "", "too long", http.StatusRequestEntityTooLarge, "file exceeds max size"},
+		{http.MethodPost, upload, "", "hello", http.StatusOK, "data written successfully"},
+		{http.MethodPost, upload, "", "again", http.StatusForbidden, "url already used"},
+		{http.MethodGet, download, "", "", http.StatusForbidden, "url already used"},
+		{http.MethodGet, upload, "", "", http.StatusForbidden, "invalid signature"},
+		{http.MethodPost, ts.URL + presignPath, "", `{"method": "GET", "path": "docs"}`, http.StatusUnauthorized, "empty Authorization header"},
+		{http.MethodPost, ts.URL + presignPath, defaultCollection, `{"method": "PUT", "path": "docs"}`, http.StatusBadRequest, "method should be GET or POST"},
+		{http.MethodPost, ts.URL + presignPath, defaultCollection, `{"method": "POST", "path": ""}`, http.StatusBadRequest, "file name is not provided"},
+		{http.MethodPost, ts.URL + presignPath, defaultCollection, `{"method": "GET", "path": "docs", "max_size": 10}`, http.StatusBadRequest, "max size is allowed only for POST"},
+		{http.MethodPost, ts.URL + presignPath, defaultCollection, `{"method": "GET", "path": "docs", "expires": "1000h"}`, http.StatusBadRequest, "expiration time should be within 168h0m0s"},
+		{http.MethodPost, ts.URL + presignPath, key.Token, `{"method": "POST", "path": "docs/new.txt"}`, http.StatusForbidden, "token does not allow write"},
+		{http.MethodPost, ts.URL + presignPath, key.Token, `{"method": "GET", "path": "inbox"}`, http.StatusForbidden, "path is outside of token prefix"},
+		{http.MethodPost, ts.URL + presignPath, key.Token, `{"method": "GET", "path": "docs", "expires": "2h"}`, http.StatusBadRequest, "url could not outlive api key"},
+	}
+
+	for _, test := range tt {
+		status, msg := do(test.Method, test.URL, test.Token, test.Body)
+		assert.Equal(t, test.Status, status, test.Method+" "+test.URL)
+		assert.Equal(t, test.Result, msg, test.Method+" "+test.URL)
+	}
+
+	status, msg = do(http.MethodGet, ts.URL+basePath+"/inbox/data.txt", defaultCollection, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello", msg)
+
+	// api key could presign what it is allowed to do itself
+	status, msg = do(http.MethodGet, presign(key.Token, `{"method": "GET", "path": "docs/q3%20report.txt"}`), "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "report", msg)
+
+	// failed requests do not use url up, so they could be retried
+	later := presign(defaultCollection, `{"method": "GET", "path": "docs/later.txt"}`)
+	_, msg = do(http.MethodGet, later, "", "")
+	assert.Equal(t, "cannot view node", msg)
+	status, _ = do(http.MethodPost, ts.URL+basePath+"/docs/later.txt", defaultCollection, "later")
+	require.Equal(t, http.StatusOK, status)
+	status, msg = do(http.MethodGet, later, "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "later", msg)
+	status, msg = do(http.MethodGet, later, "", "")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "url already used", msg)
+
+	// body without Content-Length is cut at max size by handler
+	upload = presign(defaultCollection, `{"method": "POST", "path": "inbox/retried.txt", "max_size": 5}`)
+	req, err := http.NewRequest(http.MethodPost, upload, ioutil.NopCloser(strings.NewReader("too long")))
+	require.Nil(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	assert.Equal(t, "cannot create node", string(b))
+	status, msg = do(http.MethodPost, upload, "", "hello")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "data written successfully", msg)
+	status, msg = do(http.MethodGet, ts.URL+basePath+"/inbox/retried.txt", defaultCollection, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello", msg)
+
+	account, _, err := r.Store.Authenticate(defaultCollection)
+	require.Nil(t, err)
+	_, err = r.Store.SetSuspended(account.ID, true)
+	require.Nil(t, err)
+	status, msg = do(http.MethodGet, download, "", "")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "account suspended", msg)
+}
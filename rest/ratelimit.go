@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter allows limited number of events per key during sliding window
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		events: make(map[string][]time.Time),
+	}
+}
+
+// allow reports if event could happen now, allowed events are remembered
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := l.recent(key, now)
+	if len(events) >= l.limit {
+		return false
+	}
+
+	l.events[key] = append(events, now)
+	return true
+}
+
+// retryAfter returns time left until next event of key is allowed
+func (l *rateLimiter) retryAfter(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := l.recent(key, now)
+	if len(events) < l.limit {
+		return 0
+	}
+
+	return events[0].Add(l.window).Sub(now)
+}
+
+// recent drops events, which are out of window, and returns the rest of them
+func (l *rateLimiter) recent(key string, now time.Time) []time.Time {
+	events := l.events[key]
+	i := 0
+	for i < len(events) && now.Sub(events[i]) >= l.window {
+		i += 1
+	}
+	events = events[i:]
+
+	if len(events) == 0 {
+		delete(l.events, key)
+	} else {
+		l.events[key] = events
+	}
+	return events
+}
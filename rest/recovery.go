@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+const defaultRecoveryTTL = time.Hour
+
+// recoveryRoutes maps token rotation and recovery of lost tokens
+func (rest *Rest) recoveryRoutes(router *mux.Router) {
+	router.HandleFunc(rotatePath, rest.rotateToken).Methods("POST")
+	router.HandleFunc(recoverPath, rest.requestRecovery).Methods("POST")
+	router.HandleFunc(recoverPath+"/{code}", rest.recoverToken).Methods("POST")
+	router.HandleFunc(emailPath, rest.requestEmail).Methods("PUT")
+	router.HandleFunc(emailPath+"/{code}", rest.confirmEmail).Methods("GET")
+}
+
+// rotateToken issues new main token for the caller, old token stops working immediately
+func (rest *Rest) rotateToken(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	token := newToken()
+	if err := rest.Store.RotateToken(collection, token); err != nil {
+		sendErrStatus(w, err, "cannot rotate token", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := w.Write([]byte(token)); err != nil {
+		log.Println(err)
+	}
+}
+
+type recoveryRequest struct {
+	Email string `json:"email"`
+}
+
+// requestRecovery emails one-time recovery link to registered address
+// response does not tell if address is registered, so it could not be used for finding accounts
+func (rest *Rest) requestRecovery(w http.ResponseWriter, r *http.Request) {
+	req := &recoveryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Email == "" {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	base, ok := rest.emailURL(w)
+	if !ok {
+		return
+	}
+
+	// recovery emails share limiter with share emails, keyed by address instead of account
+	if !rest.emailLimiter.allow("recover:"+strings.ToLower(req.Email), time.Now()) {
+		sendErrStatus(w, nil, "too many emails, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	ttl := rest.RecoveryTTL
+	if ttl == 0 {
+		ttl = defaultRecoveryTTL
+	}
+
+	code := randomToken(32)
+	account, err := rest.Store.CreateRecovery(req.Email, code, time.Now().Add(ttl))
+	switch {
+	case errors.Cause(err) == store.ErrAccountNotFound:
+		log.Printf("[WARN] recovery requested for unknown email %s", req.Email)
+	case err != nil:
+		sendErrStatus(w, err, "cannot create recovery", http.StatusInternalServerError)
+		return
+	default:
+		link := base + recoverPath + "/" + code
+		body := "to get new token for your dbfs account run (link works once and expires in " + ttl.String() + "):\n" +
+			"curl -X POST " + link + "\n"
+		if _, err := rest.Email.Send(account.Email, body); err != nil {
+			sendErrStatus(w, err, "cannot send recovery email", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Write([]byte("recovery link sent. check email"))
+}
+
+// recoverToken issues new main token for account, which recovery code was sent to
+// existing collection is kept, only token is replaced
+func (rest *Rest) recoverToken(w http.ResponseWriter, r *http.Request) {
+	token := newToken()
+	_, err := rest.Store.Recover(mux.Vars(r)["code"], token)
+	switch errors.Cause(err) {
+	case nil:
+	case store.ErrRecoveryNotFound:
+		sendErrStatus(w, err, "recovery link is invalid or already used", http.StatusNotFound)
+		return
+	case store.ErrRecoveryExpired:
+		sendErrStatus(w, err, "recovery link expired", http.StatusGone)
+		return
+	default:
+		sendErrStatus(w, err, "cannot recover token", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := w.Write([]byte(token)); err != nil {
+		log.Println(err)
+	}
+}
+
+// requestEmail emails verification link for setting email of account, which has none
+// accounts migrated from token-named collections have no email, so they could not use recovery and password login until it is set
+func (rest *Rest) requestEmail(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	req := &recoveryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || !strings.Contains(req.Email, "@") {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if account(r).Email != "" {
+		sendErrStatus(w, nil, "account already has email", http.StatusConflict)
+		return
+	}
+	base, ok := rest.emailURL(w)
+	if !ok {
+		return
+	}
+
+	// verification emails share limiter with share emails of the same account
+	if !rest.emailLimiter.allow(collection, time.Now()) {
+		sendErrStatus(w, nil, "too many emails, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	ttl := rest.SignupTTL
+	if ttl == 0 {
+		ttl = defaultSignupTTL
+	}
+
+	// address is checked only when link is opened, so response does not tell if it is registered
+	link := base + emailPath + "/" + rest.emailCode(collection, req.Email, time.Now().Add(ttl))
+	body := "to set this address as email of your dbfs account open (link expires in " + ttl.String() + "):\n" + link + "\n"
+	if _, err := rest.Email.Send(req.Email, body); err != nil {
+		sendErrStatus(w, err, "cannot send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("verification link sent. check email"))
+}
+
+// confirmEmail sets email of account from verification link sent by requestEmail
+// link works once, because email could not be changed after it is set
+func (rest *Rest) confirmEmail(w http.ResponseWriter, r *http.Request) {
+	id, email, expires, err := rest.parseEmailCode(mux.Vars(r)["code"])
+	if err != nil {
+		sendErrStatus(w, err, "verification link is invalid", http.StatusNotFound)
+		return
+	}
+	if time.Now().After(expires) {
+		sendErrStatus(w, nil, "verification link expired", http.StatusGone)
+		return
+	}
+
+	_, err = rest.Store.SetEmail(id, email)
+	switch errors.Cause(err) {
+	case nil:
+	case store.ErrAccountNotFound:
+		sendErrStatus(w, err, "verification link is invalid", http.StatusNotFound)
+		return
+	case store.ErrEmailSet:
+		sendErrStatus(w, err, "account already has email", http.StatusConflict)
+		return
+	case store.ErrAccountExists:
+		sendErrStatus(w, err, "email is already registered", http.StatusConflict)
+		return
+	default:
+		sendErrStatus(w, err, "cannot set email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("email set successfully. token recovery and password login could be used now"))
+}
+
+// emailCode creates verification code in form of "<account id>.<expiration unix time>.<hex encoded email>.<signature>"
+// nothing is stored until link is opened, signature keeps account and email from being forged
+func (rest *Rest) emailCode(id string, email string, expires time.Time) string {
+	payload := id + "." + strconv.FormatInt(expires.Unix(), 10) + "." + hex.EncodeToString([]byte(email))
+	return payload + "." + rest.sign("email:"+payload)
+}
+
+// parseEmailCode checks signature of code created by emailCode and returns account ID, email and expiration time
+func (rest *Rest) parseEmailCode(code string) (string, string, time.Time, error) {
+	parts := strings.Split(code, ".")
+	if len(parts) != 4 {
+		return "", "", time.Time{}, errors.New("malformed email code")
+	}
+
+	payload := strings.Join(parts[:3], ".")
+	if !rest.verifySignature("email:"+payload, parts[3]) {
+		return "", "", time.Time{}, errors.New("invalid signature of email code")
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", "", time.Time{}, errors.Wrap(err, "invalid expiration of email code")
+	}
+	email, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return "", "", time.Time{}, errors.Wrap(err, "invalid email of email code")
+	}
+	return parts[0], string(email), time.Unix(expires, 0), nil
+}
@@ -0,0 +1,166 @@
+package rest
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecovery(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	mailer := &recordingEmail{sent: make(map[string]string)}
+	r.Email = mailer
+	r.PublicURL = "https://dbfs.example.com"
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	do := func(method string, url string, token string, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", token)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+
+	token := registerAccount(t, ts, mailer, "random@gmail.com")
+	status, _ := do(http.MethodPost, basePath+"/file", token, "data")
+	require.Equal(t, http.StatusOK, status)
+
+	// rotation
+	status, rotated := do(http.MethodPost, rotatePath, token, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, rotated, 128)
+	status, msg := do(http.MethodGet, basePath+"/file", token, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid token", msg)
+	status, msg = do(http.MethodGet, basePath+"/file", rotated, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "data", msg)
+	status, _ = do(http.MethodPost, rotatePath, "", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// unknown addresses get the same response
+	status, msg = do(http.MethodPost, recoverPath, "", `{"email": "nobody@gmail.com"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "recovery link sent. check email", msg)
+	assert.Empty(t, mailer.sent["nobody@gmail.com"])
+
+	status, msg = do(http.MethodPost, recoverPath, "", `{"email": "random@gmail.com"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "recovery link sent. check email", msg)
+	email := mailer.sent["random@gmail.com"]
+	start := strings.Index(email, "https://dbfs.example.com"+recoverPath+"/")
+	require.True(t, start >= 0, email)
+	link := strings.TrimSpace(email[start:])
+
+	status, recovered := do(http.MethodPost, strings.TrimPrefix(link, "https://dbfs.example.com"), "", "")
+	require.Equal(t, http.StatusOK, status)
+	status, msg = do(http.MethodGet, basePath+"/file", recovered, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "data", msg)
+	status, _ = do(http.MethodGet, basePath+"/file", rotated, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, msg = do(http.MethodPost, strings.TrimPrefix(link, "https://dbfs.example.com"), "", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "recovery link is invalid or already used", msg)
+
+	// link is never built from Host header of request
+	r.PublicURL = ""
+	req, err := http.NewRequest(http.MethodPost, ts.URL+recoverPath, strings.NewReader(`{"email": "random@gmail.com"}`))
+	require.Nil(t, err)
+	req.Host = "evil.example"
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotContains(t, mailer.sent["random@gmail.com"], "evil.example")
+}
+
+func TestSetEmail(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	mailer := &recordingEmail{sent: make(map[string]string)}
+	r.Email = mailer
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	do := func(method string, url string, token string, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", token)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+	link := func(address string) string {
+		email := mailer.sent[address]
+		start := strings.Index(email, "https://dbfs.example.com"+emailPath+"/")
+		require.True(t, start >= 0, email)
+		return strings.TrimPrefix(strings.TrimSpace(email[start:]), "https://dbfs.example.com")
+	}
+
+	// default collection has no email, like accounts migrated from older versions
+	status, _ := do(http.MethodPut, emailPath, "", `{"email": "neo@gmail.com"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, msg := do(http.MethodPut, emailPath, defaultCollection, `{"email": "not an email"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid request body", msg)
+
+	status, msg = do(http.MethodPut, emailPath, defaultCollection, `{"email": "neo@gmail.com"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "verification link sent. check email", msg)
+	confirm := link("neo@gmail.com")
+
+	// forged links are rejected
+	parts := strings.Split(confirm, ".")
+	parts[2] = hex.EncodeToString([]byte("smith@gmail.com"))
+	status, msg = do(http.MethodGet, strings.Join(parts, "."), "", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "verification link is invalid", msg)
+
+	status, msg = do(http.MethodGet, confirm, "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "email set successfully. token recovery and password login could be used now", msg)
+	account, err := r.Store.AccountByEmail("neo@gmail.com")
+	require.Nil(t, err)
+	assert.Equal(t, defaultCollection, account.ID)
+
+	// email is set once, so link works once
+	status, msg = do(http.MethodGet, confirm, "", "")
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "account already has email", msg)
+	status, msg = do(http.MethodPut, emailPath, defaultCollection, `{"email": "other@gmail.com"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "account already has email", msg)
+
+	// password login works now
+	status, _ = do(http.MethodPut, passwordPath, defaultCollection, `{"password": "follow the white rabbit"}`)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	// address of other account could not be taken
+	_, err = r.Store.CreateAccount("migrated", "", "migrated token")
+	require.Nil(t, err)
+	status, _ = do(http.MethodPut, emailPath, "migrated token", `{"email": "NEO@gmail.com"}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, msg = do(http.MethodGet, link("NEO@gmail.com"), "", "")
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "email is already registered", msg)
+}
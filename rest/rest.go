@@ -1,12 +1,17 @@
 package rest
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/mind-rot/dbfs/email"
@@ -15,23 +20,89 @@ import (
 )
 
 const (
-	basePath   = "/db"
-	sharePath  = "/share"
-	sharedPath = "/shared"
+	basePath     = "/db"
+	sharePath    = "/share"
+	sharedPath   = "/shared"
+	sharesPath   = "/shares"
+	uploadsPath  = "/uploads"
+	mountsPath   = "/mounts"
+	sitePath     = "/site"
+	aliasesPath  = "/aliases"
+	aliasPath    = "/s"
+	keysPath     = "/keys"
+	rotatePath   = "/token/rotate"
+	recoverPath  = "/recover"
+	verifyPath   = "/verify"
+	adminPath    = "/admin"
+	invitesPath  = "/invites"
+	loginPath    = "/login"
+	logoutPath   = "/logout"
+	passwordPath = "/password"
+	presignPath  = "/presign"
+	emailPath    = "/email"
 )
 
 type Rest struct {
-	Store     *store.Store
-	Email     email.EmailService
-	Whitelist string
+	Store *store.Store
+	Email email.EmailService
+	// Admins is comma-separated list of emails of accounts, which always have admin role
+	// it bootstraps the role, other accounts get it from admins (see setRole)
+	Admins string
+
+	// UploadTTL is time given for finishing resumable upload
+	UploadTTL time.Duration
+	// MaxUploadSize limits length of resumable upload, 0 means no limit
+	MaxUploadSize int64
+
+	// PublicURL is base of absolute links sent to users (like "https://dbfs.example.com"),
+	// links returned to the caller are built from request host when empty, but emails with links are refused then
+	PublicURL string
+	// ShareEmailLimit is number of share emails single user could send during ShareEmailWindow
+	ShareEmailLimit  int
+	ShareEmailWindow time.Duration
+	// SiteMaxAge is time, during which pages of site shares could be cached
+	SiteMaxAge time.Duration
+	// RecoveryTTL is time, during which emailed recovery link could be used
+	RecoveryTTL time.Duration
+	// SignupTTL is time, during which emailed verification link could be used
+	SignupTTL time.Duration
+	// SessionTTL is lifetime of session opened by login, defaultSessionTTL is used when not set
+	SessionTTL time.Duration
+	// InviteAllowance is number of registrations, which invites of single user could be used for
+	InviteAllowance int
+	// Secret is key for signing links, random key is used when empty, so links do not survive restart
+	Secret string
+	// Authenticators resolve credentials of requests in order, DefaultAuthenticators are used when nil
+	// presigned urls and session cookies are always checked after them
+	Authenticators []Authenticator
+
+	emailLimiter *rateLimiter
+	secret       []byte
 }
 
 // Router creates router instance with mapped routes
 func (rest *Rest) Router() *mux.Router {
 	router := mux.NewRouter()
+	router.Use(rest.authenticate)
+
+	limit, window := rest.ShareEmailLimit, rest.ShareEmailWindow
+	if limit == 0 {
+		limit = defaultShareEmailLimit
+	}
+	if window == 0 {
+		window = defaultShareEmailWindow
+	}
+	rest.emailLimiter = newRateLimiter(limit, window)
+
+	rest.secret = []byte(rest.Secret)
+	if len(rest.secret) == 0 {
+		rest.secret = make([]byte, 32)
+		rand.Read(rest.secret)
+	}
 
 	router.HandleFunc("/", rest.home).Methods("GET")
 	router.HandleFunc("/register", rest.register).Methods("POST")
+	router.HandleFunc(verifyPath+"/{code}", rest.verify).Methods("GET")
 	router.HandleFunc("/help", rest.help).Methods("GET")
 	router.HandleFunc("/examples", rest.examples).Methods("GET")
 
@@ -40,22 +111,48 @@ func (rest *Rest) Router() *mux.Router {
 	dbSubrouter.Use(rest.stripPrefix(basePath))
 	dbSubrouter.PathPrefix("").HandlerFunc(rest.view).Methods("GET")
 	dbSubrouter.PathPrefix("").HandlerFunc(rest.put).Methods("POST")
+	dbSubrouter.PathPrefix("").HandlerFunc(rest.patch).Methods("PATCH")
+	dbSubrouter.PathPrefix("").HandlerFunc(rest.mkdir).Methods("MKCOL")
 	dbSubrouter.PathPrefix("").HandlerFunc(rest.delete).Methods("DELETE")
 
 	// share functionality
-	sharedSubrouter := router.PathPrefix(sharedPath).Subrouter()
-	sharedSubrouter.Use(rest.stripPrefix(sharedPath))
-	sharedSubrouter.PathPrefix("").HandlerFunc(rest.shared).Methods("GET")
-	sharedSubrouter.PathPrefix("").HandlerFunc(rest.deleteShared).Methods("DELETE")
+	rest.shareRoutes(router)
+
+	// human-friendly names of shares
+	rest.aliasRoutes(router)
+
+	// folders served as websites
+	rest.siteRoutes(router)
+
+	// resumable uploads
+	rest.uploadRoutes(router)
+
+	// shares of other users attached to own tree
+	rest.mountRoutes(router)
+
+	// additional tokens with limited access
+	rest.apiKeyRoutes(router)
+
+	// replacing leaked and lost tokens
+	rest.recoveryRoutes(router)
 
-	shareSubrouter := router.PathPrefix(sharePath).Subrouter()
-	shareSubrouter.Use(rest.stripPrefix(sharePath))
-	shareSubrouter.PathPrefix("").HandlerFunc(rest.share).Methods("GET")
+	// registration without whitelist
+	rest.inviteRoutes(router)
+
+	// password login for browsers
+	rest.sessionRoutes(router)
+
+	// one-off urls for single file without token
+	rest.presignRoutes(router)
+
+	// server management
+	rest.adminRoutes(router)
 
 	return router
 }
 
 func sendErr(w http.ResponseWriter, err error, msg string) {
+	markFailed(w)
 	if err == nil {
 		log.Printf("[ERROR] %s", errors.New(msg))
 	} else {
@@ -65,26 +162,70 @@ func sendErr(w http.ResponseWriter, err error, msg string) {
 	w.Write([]byte(msg))
 }
 
-// splitPath will split input string by "/"
-// also it will filter out redundant chars (imagine like "a///b/c", will lead to [a, b, c])
-func splitPath(path string) []string {
-	keys := strings.Split(path, "/")
-	filterdKeys := make([]string, 0)
-	// when splitting string, there could appear some garbage chars like "/" or ""
-	for _, key := range keys {
-		if key == "" || key == "/" {
-			continue
-		}
-		filterdKeys = append(filterdKeys, key)
+// sendErrStatus works like sendErr, but also sets response status code
+func sendErrStatus(w http.ResponseWriter, err error, msg string, status int) {
+	markFailed(w)
+	if err == nil {
+		log.Printf("[ERROR] %s", errors.New(msg))
+	} else {
+		log.Printf("[ERROR] %s", errors.Wrap(err, msg))
 	}
-	return filterdKeys
+
+	w.WriteHeader(status)
+	w.Write([]byte(msg))
+}
+
+// sendJSON writes value as JSON response
+func sendJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+// sendTree responds with tree of the caller after change, done describes the change
+// credentials limited by path prefix (API keys, presigned urls) do not see the whole tree, they get only done message
+func (rest *Rest) sendTree(w http.ResponseWriter, r *http.Request, collection string, done string) {
+	if !covers(r, []string{}) {
+		w.Write([]byte(done))
+		return
+	}
+
+	b, err := rest.Store.Get(collection, nil)
+	if err != nil {
+		sendErr(w, err, done+", but cannot view result")
+		return
+	}
+	if _, err = w.Write(b); err != nil {
+		log.Println(err)
+	}
+}
+
+// randomToken generates random hex string from given number of bytes
+func randomToken(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// newToken generates main token of account
+func newToken() string {
+	return randomToken(64)
+}
+
+// parsePath converts escaped request path into list of names
+// splitting is done on escaped path, so "%2F" stays inside the name
+func (rest *Rest) parsePath(r *http.Request) ([]string, error) {
+	return rest.Store.ParsePath(r.URL.EscapedPath())
 }
 
 // stripPrefix removes prefix from request url
+// escaped form of path is trimmed as well, otherwise it will not match decoded path anymore
 func (rest *Rest) stripPrefix(prefix string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+			r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -92,16 +233,23 @@ func (rest *Rest) stripPrefix(prefix string) func(http.Handler) http.Handler {
 
 // view return the current state of database in form of tree view
 func (rest *Rest) view(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
+	collection := accountID(r)
+	if collection == "" {
 		sendErr(w, nil, "empty Authorization header")
 		return
 	}
 
-	keys := splitPath(r.URL.Path)
-	b, err := rest.Store.Get(token, keys)
+	keys, err := rest.parsePath(r)
 	if err != nil {
-		sendErr(w, err, "cannot view node")
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if !authorizePath(w, r, store.ScopeRead, keys) {
+		return
+	}
+	b, err := rest.Store.Get(collection, keys)
+	if err != nil {
+		sendShareErr(w, err, "cannot view node")
 		return
 	}
 	if _, err = w.Write(b); err != nil {
@@ -111,177 +259,371 @@ func (rest *Rest) view(w http.ResponseWriter, r *http.Request) {
 
 // put creates new record in database. Returns state of database after write
 // Take "multipart/form-data" request with "file" key
+// with "append=1" query or "X-Dbfs-Append" header, adds body to the end of file instead
 func (rest *Rest) put(w http.ResponseWriter, r *http.Request) {
-	keys := splitPath(r.URL.Path)
-	token := r.Header.Get("Authorization")
-	if token == "" {
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	collection := accountID(r)
+	if collection == "" {
 		sendErr(w, nil, "empty Authorization header")
 		return
 	}
-
-	err := rest.Store.Put(token, keys, r.Body)
-	if err != nil {
-		sendErr(w, err, "cannot create node")
+	if !authorizePath(w, r, store.ScopeWrite, keys) {
 		return
 	}
 
-	b, err := rest.Store.Get(token, nil)
-	if err != nil {
-		sendErr(w, err, "data written successfully, but cannot view result")
+	if r.URL.Query().Get("from-share") != "" {
+		rest.fork(w, r)
 		return
 	}
-	if _, err = w.Write(b); err != nil {
-		log.Println(err)
+
+	// path with trailing slash is a folder
+	if strings.HasSuffix(r.URL.Path, "/") {
+		rest.mkdir(w, r)
+		return
 	}
-}
 
-// delete removes value from database
-// returnes current state of tree (GET / route)
-func (rest *Rest) delete(w http.ResponseWriter, r *http.Request) {
-	keys := splitPath(r.URL.Path)
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		sendErr(w, nil, "empty Authorization header")
+	if isAppend(r) {
+		size, err := rest.Store.Append(collection, keys, r.Body)
+		if err != nil {
+			sendShareErrStatus(w, err, "cannot append to node", http.StatusBadRequest)
+			return
+		}
+		sendSize(w, size)
 		return
 	}
 
-	err := rest.Store.Delete(token, keys)
+	err = rest.Store.Put(collection, keys, r.Body)
 	if err != nil {
-		sendErr(w, err, "cannot delete node")
+		sendShareErr(w, err, "cannot create node")
 		return
 	}
 
-	b, err := rest.Store.Get(token, nil)
+	rest.sendTree(w, r, collection, "data written successfully")
+}
+
+// mkdir creates empty folder with all missing parents. Returns state of database after write
+func (rest *Rest) mkdir(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
 	if err != nil {
-		sendErr(w, err, "data deleted successfully, but cannot view result")
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	collection := accountID(r)
+	if collection == "" {
+		sendErr(w, nil, "empty Authorization header")
+		return
+	}
+	if !authorizePath(w, r, store.ScopeWrite, keys) {
 		return
 	}
 
-	if _, err = w.Write(b); err != nil {
-		log.Println(err)
+	err = rest.Store.Mkdir(collection, keys)
+	if err != nil {
+		sendShareErr(w, err, "cannot create folder")
+		return
 	}
-}
 
-type registerRequest struct {
-	Email string `json:"email"`
+	rest.sendTree(w, r, collection, "folder created successfully")
 }
 
-// register creates token for user
-// token will also be users private bucket name
-func (rest *Rest) register(w http.ResponseWriter, r *http.Request) {
-	contains := func(str string, arr []string) bool {
-		for _, elem := range arr {
-			if str == elem {
-				return true
-			}
-		}
-		return false
+// fork copies element of share given by "from-share" query param to request path. Returns state of database after write
+// "path" query param points to element inside the share, whole share is copied when it is not set
+// password of protected share is taken from X-Share-Password header
+func (rest *Rest) fork(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+	if len(keys) == 0 {
+		sendErrStatus(w, nil, "destination path should be provided", http.StatusBadRequest)
+		return
+	}
+	if !authorizePath(w, r, store.ScopeWrite, keys) {
+		return
 	}
-	req := &registerRequest{}
 
-	err := json.NewDecoder(r.Body).Decode(req)
+	from, err := rest.Store.ParsePath(r.URL.Query().Get("path"))
 	if err != nil {
-		sendErr(w, err, "invalid request body")
+		sendErrStatus(w, err, "invalid share path", http.StatusBadRequest)
 		return
 	}
 
-	whitelist := strings.Split(rest.Whitelist, ",")
-	if !contains(req.Email, whitelist) {
-		sendErr(w, nil, "current email is not whitelisted")
+	err = rest.Store.Fork(collection, keys, r.URL.Query().Get("from-share"), r.Header.Get(sharePasswordHeader), from)
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot fork share", http.StatusBadRequest)
 		return
 	}
 
-	// generate token
-	b := make([]byte, 64)
-	rand.Read(b)
-	token := fmt.Sprintf("%x", b)
+	rest.sendTree(w, r, collection, "share forked successfully")
+}
 
-	_, err = rest.Email.Send(req.Email, token)
+// patch overwrites part of file, described by "Content-Range: bytes <start>-<end>/<size|*>" header
+// responds with file size after write
+func (rest *Rest) patch(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
 	if err != nil {
-		sendErr(w, err, "cannot send email with token")
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+	if !authorizePath(w, r, store.ScopeWrite, keys) {
 		return
 	}
 
-	// create collection with token value
-	err = rest.Store.Create(token)
+	start, end, err := parseContentRange(r.Header.Get("Content-Range"))
 	if err != nil {
-		sendErr(w, err, "cannot register")
+		sendErrStatus(w, err, "invalid Content-Range header", http.StatusBadRequest)
 		return
 	}
 
-	w.Write([]byte("registration successful. check email"))
-}
-
-// create shared folder
-func (rest *Rest) share(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		sendErr(w, nil, "empty Authorization header")
+	// body should match declared range exactly
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, end-start+2))
+	if err != nil {
+		sendErrStatus(w, err, "cannot read request body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) != end-start+1 {
+		sendErrStatus(w, nil, "body length does not match Content-Range", http.StatusBadRequest)
 		return
 	}
 
-	// generate token
-	b := make([]byte, 16)
-	rand.Read(b)
-	sharedToken := fmt.Sprintf("%x", b)
-
-	keys := splitPath(r.URL.Path)
-	err := rest.Store.Share(token, keys, sharedToken)
+	size, err := rest.Store.WriteAt(collection, keys, start, bytes.NewReader(body))
+	if errors.Cause(err) == store.ErrInvalidOffset {
+		sendErrStatus(w, err, "range start is beyond the end of file", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
 	if err != nil {
-		sendErr(w, err, "cannot share node")
+		sendShareErrStatus(w, err, "cannot write node", http.StatusBadRequest)
 		return
 	}
 
-	if _, err = w.Write([]byte(sharedToken)); err != nil {
+	sendSize(w, size)
+}
+
+// isAppend checks if request asks for append mode
+func isAppend(r *http.Request) bool {
+	for _, v := range []string{r.URL.Query().Get("append"), r.Header.Get("X-Dbfs-Append")} {
+		if on, err := strconv.ParseBool(v); err == nil && on {
+			return true
+		}
+	}
+	return false
+}
+
+// sendSize writes file size after partial write both as header and response body
+func sendSize(w http.ResponseWriter, size int64) {
+	w.Header().Set("X-Dbfs-Size", strconv.FormatInt(size, 10))
+	if _, err := w.Write([]byte(strconv.FormatInt(size, 10))); err != nil {
 		log.Println(err)
 	}
 }
 
-// shared is a route for getting shared info
-func (rest *Rest) shared(w http.ResponseWriter, r *http.Request) {
-	keys := splitPath(r.URL.Path)
-	if len(keys) <= 1 {
-		sendErr(w, nil, "search path should be provided")
-		return
+// parseContentRange returns first and last (inclusive) byte positions from Content-Range header
+// total size part is not used, because file could grow during write
+func parseContentRange(header string) (int64, int64, error) {
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, errors.New("only bytes unit is supported")
+	}
+
+	rangeSpec := strings.SplitN(strings.TrimPrefix(header, "bytes "), "/", 2)[0]
+	bounds := strings.SplitN(rangeSpec, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, errors.Errorf("invalid range \"%s\"", rangeSpec)
 	}
-	b, err := rest.Store.Get(keys[0], keys[1:len(keys)-1])
+
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
 	if err != nil {
-		sendErr(w, err, "cannot view node")
-		return
+		return 0, 0, errors.Wrap(err, "invalid range start")
 	}
-	if _, err = w.Write(b); err != nil {
-		log.Println(err)
+	end, err := strconv.ParseInt(bounds[1], 10, 64)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid range end")
 	}
+	if start < 0 || end < start {
+		return 0, 0, errors.Errorf("invalid range \"%s\"", rangeSpec)
+	}
+
+	return start, end, nil
 }
 
-// deleteShared is a route for deleting shared info
-func (rest *Rest) deleteShared(w http.ResponseWriter, r *http.Request) {
-	keys := splitPath(r.URL.Path)
+// delete removes value from database
+// returnes current state of tree (GET / route)
+func (rest *Rest) delete(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	collection := accountID(r)
+	if collection == "" {
+		sendErr(w, nil, "empty Authorization header")
+		return
+	}
+	if !authorizePath(w, r, store.ScopeDelete, keys) {
+		return
+	}
 
-	err := rest.Store.Delete(keys[0], []string{})
+	err = rest.Store.Delete(collection, keys)
 	if err != nil {
-		sendErr(w, err, "cannot delete node")
+		sendShareErr(w, err, "cannot delete node")
 		return
 	}
 
-	b, err := rest.Store.Get(keys[0], nil)
+	rest.sendTree(w, r, collection, "data deleted successfully")
+}
+
+type registerRequest struct {
+	Email string `json:"email"`
+	// Invite is code, which lets to register email missing in whitelist
+	Invite string `json:"invite"`
+	// Password is optional, it lets to log in with email instead of token (see login)
+	Password string `json:"password"`
+}
+
+// register starts registration by sending verification link to given email
+// email should be whitelisted or request should have invite code
+// account is created only when link is opened, see verify
+func (rest *Rest) register(w http.ResponseWriter, r *http.Request) {
+	req := &registerRequest{}
+
+	err := json.NewDecoder(r.Body).Decode(req)
 	if err != nil {
-		sendErr(w, err, "cannot view node")
+		sendErr(w, err, "invalid request body")
 		return
 	}
 
-	if _, err = w.Write(b); err != nil {
-		log.Println(err)
+	// deny rules win over invites, invite only replaces missing allow rule
+	invite := ""
+	err = rest.Store.CheckWhitelist(req.Email)
+	switch errors.Cause(err) {
+	case nil:
+	case store.ErrEmailDenied:
+		sendErrStatus(w, err, "current email is not allowed to register", http.StatusForbidden)
+		return
+	case store.ErrEmailNotListed:
+		if req.Invite == "" {
+			sendErr(w, nil, "current email is not whitelisted")
+			return
+		}
+		if err := rest.Store.CheckInvite(req.Invite); err != nil {
+			sendInviteErr(w, err)
+			return
+		}
+		invite = req.Invite
+	default:
+		sendErr(w, err, "cannot check whitelist")
+		return
 	}
+
+	base, ok := rest.emailURL(w)
+	if !ok {
+		return
+	}
+
+	ttl := rest.SignupTTL
+	if ttl == 0 {
+		ttl = defaultSignupTTL
+	}
+
+	// repeated registration replaces pending signup, so only the last link works
+	signup, err := rest.Store.CreateSignup(randomToken(16), req.Email, invite, req.Password, time.Now().Add(ttl))
+	switch errors.Cause(err) {
+	case nil:
+	case store.ErrAccountExists:
+		// response is the same as for new address, so registration could not be used for finding accounts
+		body := "you already have dbfs account. to get new token run:\n" +
+			"curl -X POST -d '{\"email\": \"" + req.Email + "\"}' " + base + recoverPath + "\n"
+		if _, err := rest.Email.Send(req.Email, body); err != nil {
+			sendErr(w, err, "cannot send verification email")
+			return
+		}
+		w.Write([]byte("verification link sent. check email"))
+		return
+	case store.ErrWeakPassword:
+		sendErrStatus(w, err, weakPasswordMessage, http.StatusBadRequest)
+		return
+	default:
+		sendErr(w, err, "cannot register")
+		return
+	}
+
+	link := base + verifyPath + "/" + rest.signupCode(signup)
+	body := "to finish dbfs registration open (link expires in " + ttl.String() + "):\n" + link + "\n"
+	_, err = rest.Email.Send(req.Email, body)
+	if err != nil {
+		sendErr(w, err, "cannot send verification email")
+		return
+	}
+
+	w.Write([]byte("verification link sent. check email"))
 }
 
 func (rest *Rest) help(w http.ResponseWriter, r *http.Request) {
 	help := `request examples:
 /db       GET     list root path
 /db       POST    write file (should be sent as data-binary request) to given path
+/db/      POST    create empty folder (path should end with "/", MKCOL method also works)
+/db       POST    copy share given by "from-share" query param (and optional "path" inside it) to given path
+/db       PATCH   overwrite part of file given by Content-Range header
 /db       DELETE  deletes given element
-/share    GET     copies node to publick space
+/share    GET     copies node (folder or file) to publick space
 /shared   GET     get shared data
+/shared   POST    upload file into drop box share
+/shared   DELETE  revoke share (only by owner)
+/aliases  GET     list own share aliases
+/aliases  PUT     create alias for own share or re-point it to other share
+/aliases  DELETE  revoke alias
+/s        GET     get shared data by alias (POST uploads into drop box)
+/site     GET     serve folder shared with mode=site as website
+/shares   GET     list own shares or inspect one of them
+/shares   DELETE  revoke share
+/shares   POST    email share link to recipients (/shares/<token>/email)
+/mounts   GET     list shares of others attached to own tree
+/mounts   POST    attach share of other user at given path (read-only, DELETE on /db detaches)
+/uploads  POST    create resumable upload (tus protocol)
+/uploads  HEAD    get offset of resumable upload
+/uploads  PATCH   write chunk of resumable upload
+/uploads  DELETE  terminate resumable upload
+/keys     GET     list own API keys
+/keys     POST    create API key with label, scopes (read, write, delete, share), path prefix and expiration
+/keys     DELETE  revoke API key
+/presign  POST    create signed one-time url for single GET or POST of given path, with expiration and max size
+/token    POST    issue new token (/token/rotate), old one stops working
+/recover  POST    email one-time recovery link to registered address
+/recover  POST    get new token by recovery link (/recover/<code>)
+/email    PUT     set email of account without one (accounts of older versions), verification link is sent
+/email    GET     confirm email from verification link (/email/<code>)
+/invites  GET     list own invite codes and registrations made with them
+/invites  POST    create invite code for registration without whitelist, with max_uses and expiration
+/invites  DELETE  revoke invite code (/invites/<code>)
+/login    POST    log in with email and password, session cookie is set, csrf_token should be sent in X-CSRF-Token header
+/logout   POST    close session
+/password PUT     set password for login, closes all sessions
+/verify   GET     confirm email from registration link and get token (/verify/<code>)
+/admin    GET     list registration rules (/admin/whitelist, admins only)
+/admin    POST    add exact, "*@domain" or regex registration rule, "deny" forbids matching emails
+/admin    DELETE  remove registration rule (/admin/whitelist/<id>)
+/admin    GET     list invite codes of all users (/admin/invites)
+/admin    GET     list accounts with used space (/admin/accounts)
+/admin    PUT     grant or take admin role (/admin/accounts/<id>/role)
+/admin    POST    suspend or restore account (/admin/accounts/<id>/suspend, /admin/accounts/<id>/unsuspend)
+/admin    POST    replace token of account and get new one (/admin/accounts/<id>/rotate)
+/admin    DELETE  delete account with all its data and shares (/admin/accounts/<id>)
+/admin    GET     database statistics (/admin/stats)
 /help     GET     API
 /examples GET     examples
 `
@@ -291,14 +633,53 @@ func (rest *Rest) help(w http.ResponseWriter, r *http.Request) {
 func (rest *Rest) examples(w http.ResponseWriter, r *http.Request) {
 	help := `request examples:
 register          curl -w '\n' -X POST -d '{"email": "myEpicEmail@gmail.com"}' localhost:8080/register
+confirm email     curl -w '\n' localhost:8080/verify/<code>
+register invited  curl -w '\n' -X POST -d '{"email": "friend@mail.com", "invite": "<code>"}' localhost:8080/register
 write file        curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers -d $HOME/data.txt localhost:8080/db/data.txt
 write file        curl -w '\n' -X POST -H "Authorization: <toke>" -d $HOME/data.txt localhost:8080/db/data.txt
+bearer token      curl -w '\n' -X GET -H "Authorization: Bearer <token>" localhost:8080/db
+basic auth        curl -w '\n' -X GET -u :<token> localhost:8080/db
+create folder     curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers localhost:8080/db/someFolder/
+append to file    curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers --data-binary 'new line' localhost:8080/db/log.txt?append=1
+fork share        curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers 'localhost:8080/db/imported?from-share=<token>&path=someFolder/sub'
+overwrite part    curl -w '\n' -X PATCH -H @$HOME/Documents/dbfs_headers -H "Content-Range: bytes 10-17/*" --data-binary 'new data' localhost:8080/db/data.txt
 download file     curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers localhost:8080/db/data.txt
 view root tree    curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers localhost:8080/db
 delete file       curl -w '\n' -X DELETE -H @$HOME/Documents/dbfs_headers localhost:8080/db/data.txt
 delete folder     curl -w '\n' -X DELETE -H @$HOME/Documents/dbfs_headers localhost:8080/db/someFolder
 share folder      curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers localhost:8080/share/someFolder
+live share        curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers 'localhost:8080/share/someFolder?mode=live'
+limited share     curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers -H "X-Share-Password: secret" 'localhost:8080/share/someFolder?expires=48h&max-downloads=5'
+drop box share    curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers 'localhost:8080/share/inbox?mode=dropbox&max-size=10485760&max-uploads=20'
+upload to drop    curl -w '\n' -X POST --data-binary @$HOME/report.pdf localhost:8080/shared/<token>/report.pdf
+share file        curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers localhost:8080/share/someFolder/report.pdf
+site share        curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers 'localhost:8080/share/public_html?mode=site'
+open site         curl -w '\n' localhost:8080/site/<token>/
+download shared   curl -O -J localhost:8080/shared/<token>
 download shared   curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers localhost:8080/shared/<token>/someFolder
+list shares       curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers localhost:8080/shares
+email share       curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers -d '{"recipients": ["friend@mail.com"], "message": "photos from trip"}' localhost:8080/shares/<token>/email
+set alias         curl -w '\n' -X PUT -H @$HOME/Documents/dbfs_headers -d '{"token": "<token>"}' localhost:8080/aliases/q3-report
+shared by alias   curl -w '\n' localhost:8080/s/q3-report
+revoke share      curl -w '\n' -X DELETE -H @$HOME/Documents/dbfs_headers localhost:8080/shares/<token>
+mount share       curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers -d '{"token": "<token>", "path": "from/alice"}' localhost:8080/mounts
+unmount share     curl -w '\n' -X DELETE -H @$HOME/Documents/dbfs_headers localhost:8080/db/from/alice
+create upload     curl -w '\n' -i -X POST -H @$HOME/Documents/dbfs_headers -H "Tus-Resumable: 1.0.0" -H "Upload-Length: 42" -H "Upload-Metadata: path $(echo -n big/data.bin | base64)" localhost:8080/uploads
+upload chunk      curl -w '\n' -X PATCH -H @$HOME/Documents/dbfs_headers -H "Tus-Resumable: 1.0.0" -H "Upload-Offset: 0" -H "Content-Type: application/offset+octet-stream" --data-binary @$HOME/chunk localhost:8080/uploads/<id>
+upload offset     curl -w '\n' -I -H @$HOME/Documents/dbfs_headers -H "Tus-Resumable: 1.0.0" localhost:8080/uploads/<id>
+create api key    curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers -d '{"label": "ci", "scopes": ["read"], "prefix": "builds", "expires": "720h"}' localhost:8080/keys
+log in            curl -w '\n' -c $HOME/.dbfs_cookies -X POST -d '{"email": "myEpicEmail@gmail.com", "password": "<password>"}' localhost:8080/login
+set password      curl -w '\n' -X PUT -H @$HOME/Documents/dbfs_headers -d '{"password": "<password>"}' localhost:8080/password
+rotate token      curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers localhost:8080/token/rotate
+recover token     curl -w '\n' -X POST -d '{"email": "myEpicEmail@gmail.com"}' localhost:8080/recover
+set email         curl -w '\n' -X PUT -H @$HOME/Documents/dbfs_headers -d '{"email": "myEpicEmail@gmail.com"}' localhost:8080/email
+invite friends    curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers -d '{"max_uses": 3, "expires": "72h"}' localhost:8080/invites
+list accounts     curl -w '\n' -X GET -H @$HOME/Documents/dbfs_headers localhost:8080/admin/accounts
+suspend account   curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers localhost:8080/admin/accounts/<id>/suspend
+allow domain      curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers -d '{"pattern": "*@ourcompany.com"}' localhost:8080/admin/whitelist
+presign download  curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers -d '{"method": "GET", "path": "docs/report.pdf", "expires": "15m"}' localhost:8080/presign
+presign upload    curl -w '\n' -X POST -H @$HOME/Documents/dbfs_headers -d '{"method": "POST", "path": "inbox/photo.jpg", "max_size": 10485760}' localhost:8080/presign
+revoke api key    curl -w '\n' -X DELETE -H @$HOME/Documents/dbfs_headers localhost:8080/keys/<id>
 `
 	w.Write([]byte(help))
 }
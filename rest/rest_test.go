@@ -46,7 +46,7 @@ func TestView(t *testing.T) {
 		},
 		{
 			"/invalid",
-			"cannot view node",
+			"invalid token",
 			"invalid token",
 		},
 	}
@@ -99,7 +99,7 @@ func TestPut(t *testing.T) {
 		{
 			"/some/path",
 			"content",
-			"cannot create node",
+			"invalid token",
 			"invalid token",
 		},
 	}
@@ -142,7 +142,7 @@ func TestDelete(t *testing.T) {
 	}{
 		{
 			"/must/have",
-			"Neo\nanswer\nme\n  and\nmust\n",
+			"Neo\nanswer\nme\n  and\nmust/\n",
 			defaultCollection,
 		},
 		{
@@ -190,7 +190,7 @@ func TestRegister(t *testing.T) {
 	}{
 		{
 			"random@gmail.com",
-			"registration successful. check email",
+			"verification link sent. check email",
 		},
 	}
 
@@ -231,9 +231,9 @@ func TestShare(t *testing.T) {
 	}{
 		{
 			"me",
-			111,
+			112,
 			32,
-			"me\n  and\n",
+			"and\n",
 			defaultCollection,
 		},
 	}
@@ -346,10 +346,16 @@ func getRest() (*Rest, error) {
 		return nil, err
 	}
 
+	err = s.SeedWhitelist([]string{"random@gmail.com", "myEpicEmail@gmail.com"})
+	if err != nil {
+		return nil, err
+	}
+
 	r := &Rest{
-		Store:     s,
-		Email:     &emailMock{},
-		Whitelist: "random@gmail.com,myEpicEmail@gmail.com",
+		Store: s,
+		Email: &emailMock{},
+		// emails with links are sent only when public url is configured
+		PublicURL: "https://dbfs.example.com",
 	}
 
 	return r, nil
@@ -366,7 +372,7 @@ func getStore() (*store.Store, error) {
 		Path: "/tmp/db123",
 	}
 
-	err := s.Create(defaultCollection)
+	_, err := s.CreateAccount(defaultCollection, "", defaultCollection)
 	if err != nil {
 		return nil, err
 	}
@@ -402,3 +408,217 @@ func getStore() (*store.Store, error) {
 
 	return s, nil
 }
+
+func TestPatch(t *testing.T) {
+	tt := []struct {
+		Path         string
+		ContentRange string
+		FileContent  string
+		Status       int
+		ResponseBody string
+	}{
+		{"/answer", "bytes 1-1/*", "3", http.StatusOK, "2"},
+		{"/answer", "bytes 2-4/5", "!!!", http.StatusOK, "5"},
+		{"/answer", "bytes 10-11/*", "!!", http.StatusRequestedRangeNotSatisfiable, "range start is beyond the end of file"},
+		{"/answer", "bytes 0-5/*", "short", http.StatusBadRequest, "body length does not match Content-Range"},
+		{"/answer", "items 0-1/*", "ab", http.StatusBadRequest, "invalid Content-Range header"},
+		{"/new\nline", "bytes 0-0/*", "!", http.StatusBadRequest, "invalid path"},
+	}
+
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	for _, test := range tt {
+		u, err := url.Parse(ts.URL)
+		require.Nil(t, err)
+		u.Path = path.Join(u.Path, basePath, test.Path)
+
+		req, err := http.NewRequest(http.MethodPatch, u.String(), strings.NewReader(test.FileContent))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+		req.Header.Set("Content-Range", test.ContentRange)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.Status, resp.StatusCode)
+		assert.Equal(t, test.ResponseBody, string(msg))
+	}
+
+	b, err := r.Store.Get(defaultCollection, []string{"answer"})
+	require.Nil(t, err)
+	assert.Equal(t, "43!!!", string(b))
+}
+
+func TestAppend(t *testing.T) {
+	tt := []struct {
+		URL          string
+		Header       string
+		FileContent  string
+		ResponseBody string
+	}{
+		{"/log?append=1", "", "first\n", "6"},
+		{"/log", "true", "second\n", "13"},
+		{"/log?append=0", "", "replaced\n", "Neo\nanswer\nlog\nme\n  and\nmust\n  have\n    been\n      like\n"},
+	}
+
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	for _, test := range tt {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+basePath+test.URL, strings.NewReader(test.FileContent))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+		if test.Header != "" {
+			req.Header.Set("X-Dbfs-Append", test.Header)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.ResponseBody, string(msg))
+	}
+
+	b, err := r.Store.Get(defaultCollection, []string{"log"})
+	require.Nil(t, err)
+	assert.Equal(t, "replaced\n", string(b))
+}
+
+func TestMkdir(t *testing.T) {
+	tt := []struct {
+		Method       string
+		Path         string
+		ResponseBody string
+	}{
+		{
+			http.MethodPost,
+			"/empty/",
+			"Neo\nanswer\nempty/\nme\n  and\nmust\n  have\n    been\n      like\n",
+		},
+		{
+			"MKCOL",
+			"/me/too",
+			"Neo\nanswer\nempty/\nme\n  and\n  too/\nmust\n  have\n    been\n      like\n",
+		},
+		{
+			http.MethodPost,
+			"/answer/",
+			"cannot create folder",
+		},
+	}
+
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	for _, test := range tt {
+		req, err := http.NewRequest(test.Method, ts.URL+basePath+test.Path, nil)
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.ResponseBody, string(msg))
+	}
+}
+
+func TestEscapedNames(t *testing.T) {
+	tt := []struct {
+		Method       string
+		Path         string
+		Status       int
+		ResponseBody string
+	}{
+		{http.MethodPost, "/a%2Fb/100%25", http.StatusOK, "Neo\na%2Fb\n  100%25\nanswer\nme\n  and\nmust\n  have\n    been\n      like\n"},
+		{http.MethodGet, "/a%2Fb", http.StatusOK, "100%25\n"},
+		{http.MethodGet, "/a%2Fb/100%25", http.StatusOK, "escaped"},
+		{http.MethodPost, "/new%0Aline", http.StatusBadRequest, "invalid path"},
+		{http.MethodPost, "/%1B%5B31mred", http.StatusBadRequest, "invalid path"},
+	}
+
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	for _, test := range tt {
+		req, err := http.NewRequest(test.Method, ts.URL+basePath+test.Path, strings.NewReader("escaped"))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.Status, resp.StatusCode)
+		assert.Equal(t, test.ResponseBody, string(msg))
+	}
+}
+
+func TestFork(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	require.Nil(t, r.Store.Create("friend"))
+	require.Nil(t, r.Store.Put("friend", []string{"docs", "sub", "notes"}, strings.NewReader("read me")))
+	require.Nil(t, r.Store.Share("friend", []string{"docs"}, "docs", store.ShareOptions{}))
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	tt := []struct {
+		Path         string
+		Status       int
+		ResponseBody string
+	}{
+		{"/imported?from-share=docs&path=docs/sub", http.StatusOK, "Neo\nanswer\nimported\n  notes\nme\n  and\nmust\n  have\n    been\n      like\n"},
+		{"/imported?from-share=docs", http.StatusBadRequest, "cannot fork share"},
+		{"/answer/docs?from-share=docs", http.StatusBadRequest, "cannot fork share"},
+		{"/other?from-share=missing", http.StatusNotFound, "share not found"},
+		{"/?from-share=docs", http.StatusBadRequest, "destination path should be provided"},
+	}
+
+	for _, test := range tt {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+basePath+test.Path, nil)
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.Status, resp.StatusCode, test.Path)
+		assert.Equal(t, test.ResponseBody, string(msg))
+	}
+
+	b, err := r.Store.Get(defaultCollection, []string{"imported", "notes"})
+	require.Nil(t, err)
+	assert.Equal(t, "read me", string(b))
+}
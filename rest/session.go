@@ -0,0 +1,204 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSessionTTL = 24 * time.Hour
+	// sessionCookie keeps session ID, it is not available to scripts
+	// cookie is not sent with cross-site requests at all, because some GET routes (like /share) change data
+	sessionCookie = "dbfs_session"
+	// csrfHeader should carry CSRF token of session for requests changing data
+	csrfHeader = "X-CSRF-Token"
+)
+
+var weakPasswordMessage = "password should be at least " + strconv.Itoa(store.MinPasswordLength) + " characters long"
+
+// sessionRoutes maps password login, which is alternative to token for browsers
+func (rest *Rest) sessionRoutes(router *mux.Router) {
+	router.HandleFunc(loginPath, rest.login).Methods("POST")
+	router.HandleFunc(logoutPath, rest.logout).Methods("POST")
+	router.HandleFunc(passwordPath, rest.setPassword).Methods("PUT")
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Account *store.Account `json:"account"`
+	// CSRF should be sent in X-CSRF-Token header of every request changing data
+	CSRF    string    `json:"csrf_token"`
+	Expires time.Time `json:"expires"`
+}
+
+// login opens session for email and password, session ID is set as HTTP-only cookie
+func (rest *Rest) login(w http.ResponseWriter, r *http.Request) {
+	req := &loginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	account, err := rest.Store.Login(req.Email, req.Password)
+	switch errors.Cause(err) {
+	case nil:
+	case store.ErrInvalidCredentials:
+		sendErrStatus(w, err, "invalid email or password", http.StatusUnauthorized)
+		return
+	case store.ErrAccountSuspended:
+		sendErrStatus(w, err, "account suspended", http.StatusForbidden)
+		return
+	default:
+		sendErrStatus(w, err, "cannot log in", http.StatusInternalServerError)
+		return
+	}
+
+	ttl := rest.SessionTTL
+	if ttl == 0 {
+		ttl = defaultSessionTTL
+	}
+
+	id := randomToken(32)
+	session, err := rest.Store.CreateSession(account.ID, id, randomToken(32), time.Now().Add(ttl))
+	if err != nil {
+		sendErrStatus(w, err, "cannot log in", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, rest.newSessionCookie(r, id, session.Expires))
+	w.Header().Set("Cache-Control", "no-store")
+	sendJSON(w, &loginResponse{Account: account, CSRF: session.CSRF, Expires: session.Expires})
+}
+
+// logout closes session of the caller and removes its cookie
+func (rest *Rest) logout(w http.ResponseWriter, r *http.Request) {
+	if id := sessionID(r); id != "" {
+		if err := rest.Store.DeleteSession(id); err != nil {
+			sendErrStatus(w, err, "cannot log out", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rest.clearSession(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type passwordRequest struct {
+	Password string `json:"password"`
+}
+
+// setPassword sets password of the caller, all sessions of the caller are closed
+func (rest *Rest) setPassword(w http.ResponseWriter, r *http.Request) {
+	collection, ok := mainToken(w, r)
+	if !ok {
+		return
+	}
+
+	req := &passwordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if account(r).Email == "" {
+		sendErrStatus(w, nil, "account without email could not have password", http.StatusBadRequest)
+		return
+	}
+
+	err := rest.Store.SetPassword(collection, req.Password)
+	if errors.Cause(err) == store.ErrWeakPassword {
+		sendErrStatus(w, err, weakPasswordMessage, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		sendErrStatus(w, err, "cannot set password", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionID(r) != "" {
+		rest.clearSession(w, r)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionPrincipal resolves session cookie to account
+// requests changing data should carry CSRF token of the session, because browser sends cookie to any site
+// invalid session is rejected, so browser does not act anonymously by mistake, but login is always allowed
+// cookie is ignored when request has Authorization header, so scripts are not affected by browser sessions
+func (rest *Rest) sessionPrincipal(r *http.Request) (*Principal, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" || r.Header.Get("Authorization") != "" || r.URL.Path == loginPath {
+		return nil, nil
+	}
+
+	account, session, err := rest.Store.AuthenticateSession(cookie.Value)
+	switch errors.Cause(err) {
+	case nil:
+	case store.ErrSessionNotFound, store.ErrSessionExpired:
+		return nil, &authError{
+			status: http.StatusUnauthorized,
+			msg:    "session expired, log in again",
+			err:    err,
+			cookie: rest.newSessionCookie(r, "", time.Time{}),
+		}
+	default:
+		return nil, err
+	}
+
+	if !safeMethod(r.Method) && subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(session.CSRF)) != 1 {
+		return nil, &authError{status: http.StatusForbidden, msg: "invalid csrf token"}
+	}
+
+	return &Principal{Account: account, Session: cookie.Value}, nil
+}
+
+// sessionID returns ID of session request was authenticated with, empty string is returned for tokens
+func sessionID(r *http.Request) string {
+	if principal := principal(r); principal != nil {
+		return principal.Session
+	}
+	return ""
+}
+
+// clearSession removes session cookie from browser
+func (rest *Rest) clearSession(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, rest.newSessionCookie(r, "", time.Time{}))
+}
+
+// newSessionCookie returns session cookie, empty id returns cookie removing session from browser
+func (rest *Rest) newSessionCookie(r *http.Request, id string, expires time.Time) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   rest.secureCookie(r),
+		SameSite: http.SameSiteStrictMode,
+	}
+	if id == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// secureCookie reports if cookie should be sent only over HTTPS
+func (rest *Rest) secureCookie(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(rest.PublicURL, "https://")
+}
+
+// safeMethod reports if method does not change data
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
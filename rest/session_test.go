@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	mailer := &recordingEmail{sent: make(map[string]string)}
+	r.Email = mailer
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.Nil(t, err)
+	browser := &http.Client{Jar: jar}
+
+	do := func(client *http.Client, method string, url string, headers map[string]string, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		require.Nil(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+	login := func(email string, password string) (int, *loginResponse) {
+		status, msg := do(browser, http.MethodPost, loginPath, nil, `{"email": "`+email+`", "password": "`+password+`"}`)
+		result := &loginResponse{}
+		if status == http.StatusOK {
+			require.Nil(t, json.Unmarshal([]byte(msg), result))
+		}
+		return status, result
+	}
+
+	// password is chosen on registration
+	status, msg := do(http.DefaultClient, http.MethodPost, "/register", nil, `{"email": "random@gmail.com", "password": "short"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "password should be at least 8 characters long", msg)
+	status, _ = do(http.DefaultClient, http.MethodPost, "/register", nil, `{"email": "random@gmail.com", "password": "there is no spoon"}`)
+	require.Equal(t, http.StatusOK, status)
+	link := verifyLink(t, mailer.sent["random@gmail.com"])
+	status, _ = do(http.DefaultClient, http.MethodGet, link[strings.Index(link, verifyPath+"/"):], nil, "")
+	require.Equal(t, http.StatusOK, status)
+	token := mailer.sent["random@gmail.com"]
+
+	status, _ = login("random@gmail.com", "there is a spoon")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, session := login("random@gmail.com", "there is no spoon")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "random@gmail.com", session.Account.Email)
+	assert.NotEmpty(t, session.CSRF)
+
+	csrf := map[string]string{csrfHeader: session.CSRF}
+	status, msg = do(browser, http.MethodPost, basePath+"/notes.txt", nil, "hello")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "invalid csrf token", msg)
+	status, _ = do(browser, http.MethodPost, basePath+"/notes.txt", csrf, "hello")
+	assert.Equal(t, http.StatusOK, status)
+	status, msg = do(browser, http.MethodGet, basePath+"/notes.txt", nil, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello", msg)
+
+	// token still works and wins over cookie
+	status, msg = do(browser, http.MethodGet, basePath+"/notes.txt", map[string]string{"Authorization": defaultCollection}, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEqual(t, "hello", msg)
+
+	// password could be set with token as well, it closes sessions
+	status, _ = do(http.DefaultClient, http.MethodPut, passwordPath, map[string]string{"Authorization": token}, `{"password": "short"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = do(http.DefaultClient, http.MethodPut, passwordPath, map[string]string{"Authorization": token}, `{"password": "follow the white rabbit"}`)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, msg = do(browser, http.MethodGet, basePath, nil, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "session expired, log in again", msg)
+	// cookie of closed session is removed
+	_, msg = do(browser, http.MethodGet, basePath, nil, "")
+	assert.Equal(t, "empty Authorization header", msg)
+
+	status, session = login("random@gmail.com", "follow the white rabbit")
+	require.Equal(t, http.StatusOK, status)
+	status, _ = do(browser, http.MethodPost, logoutPath, nil, "")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = do(browser, http.MethodPost, logoutPath, map[string]string{csrfHeader: session.CSRF}, "")
+	assert.Equal(t, http.StatusNoContent, status)
+	_, msg = do(browser, http.MethodGet, basePath, nil, "")
+	assert.Equal(t, "empty Authorization header", msg)
+}
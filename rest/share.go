@@ -0,0 +1,361 @@
+package rest
+
+import (
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+// shareRoutes maps creation, reading and management of shares
+func (rest *Rest) shareRoutes(router *mux.Router) {
+	router.HandleFunc(sharesPath, rest.listShares).Methods("GET")
+	router.HandleFunc(sharesPath+"/{token}", rest.getShare).Methods("GET")
+	router.HandleFunc(sharesPath+"/{token}", rest.revokeShare).Methods("DELETE")
+	router.HandleFunc(sharesPath+"/{token}/email", rest.emailShare).Methods("POST")
+
+	sharedSubrouter := router.PathPrefix(sharedPath).Subrouter()
+	sharedSubrouter.Use(rest.stripPrefix(sharedPath))
+	sharedSubrouter.PathPrefix("").HandlerFunc(rest.shared).Methods("GET")
+	sharedSubrouter.PathPrefix("").HandlerFunc(rest.drop).Methods("POST")
+	sharedSubrouter.PathPrefix("").HandlerFunc(rest.deleteShared).Methods("DELETE")
+
+	shareSubrouter := router.PathPrefix(sharePath).Subrouter()
+	shareSubrouter.Use(rest.stripPrefix(sharePath))
+	shareSubrouter.PathPrefix("").HandlerFunc(rest.share).Methods("GET")
+}
+
+// sharePasswordHeader carries password for creating and reading protected shares
+const sharePasswordHeader = "X-Share-Password"
+
+// create shared folder
+// "mode" query param chooses between frozen copy ("snapshot", default), pointer to node ("live")
+// folder served as website ("site") and upload-only folder ("dropbox"), limited with "max-size" (bytes per file) and "max-uploads" query params
+// share could be restricted with "expires" (duration like "24h" or RFC3339 time) and "max-downloads" query params
+// and with password from X-Share-Password header
+func (rest *Rest) share(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErr(w, nil, "empty Authorization header")
+		return
+	}
+
+	sharedToken := randomToken(16)
+
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if !authorizePath(w, r, store.ScopeShare, keys) {
+		return
+	}
+
+	opts, err := shareOptions(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid share options", http.StatusBadRequest)
+		return
+	}
+
+	err = rest.Store.Share(collection, keys, sharedToken, opts)
+	if err != nil {
+		sendErr(w, err, "cannot share node")
+		return
+	}
+
+	if _, err = w.Write([]byte(sharedToken)); err != nil {
+		log.Println(err)
+	}
+}
+
+// shared is a route for getting shared info
+// path starts with share token, rest of it points to element inside the share
+// files are sent as downloads, so root of file share works as direct download link
+func (rest *Rest) shared(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if len(keys) == 0 {
+		sendErrStatus(w, nil, "share token should be provided", http.StatusBadRequest)
+		return
+	}
+	rest.sendShared(w, r, keys[0], keys[1:])
+}
+
+// sendShared writes element of share given by token, files are sent as downloads
+func (rest *Rest) sendShared(w http.ResponseWriter, r *http.Request, token string, keys []string) {
+	node, err := rest.Store.OpenShared(token, r.Header.Get(sharePasswordHeader), keys)
+	if err != nil {
+		sendShareErr(w, err, "cannot view node")
+		return
+	}
+
+	if node.File {
+		sendFile(w, node.Name, node.Data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err = w.Write(node.Data); err != nil {
+		log.Println(err)
+	}
+}
+
+// sendFile writes file content with headers, which make browsers download it under its own name
+func sendFile(w http.ResponseWriter, name string, data []byte) {
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name})
+	if disposition == "" {
+		// name could not be represented in header
+		disposition = "attachment"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := w.Write(data); err != nil {
+		log.Println(err)
+	}
+}
+
+// drop is a route for uploading file into drop box share
+// path consists of share token and file name, name under which file was stored is returned
+func (rest *Rest) drop(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if len(keys) != 2 {
+		sendErrStatus(w, nil, "share token and file name should be provided", http.StatusBadRequest)
+		return
+	}
+
+	rest.dropShared(w, r, keys[0], keys[1])
+}
+
+// dropShared writes request body into drop box share given by token
+func (rest *Rest) dropShared(w http.ResponseWriter, r *http.Request, token string, name string) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	name, err = rest.Store.DropFile(token, r.Header.Get(sharePasswordHeader), name, ip, r.Body)
+	if err != nil {
+		sendShareErr(w, err, "cannot upload file")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if _, err = w.Write([]byte(store.EscapeName(name))); err != nil {
+		log.Println(err)
+	}
+}
+
+// deleteShared is a route for revoking share, available only for share owner
+// returns owner's tree after revoking
+func (rest *Rest) deleteShared(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if len(keys) == 0 {
+		sendErrStatus(w, nil, "share token should be provided", http.StatusBadRequest)
+		return
+	}
+	if !rest.authorizeShare(w, r, collection, keys[0]) {
+		return
+	}
+
+	err = rest.Store.RevokeShare(collection, keys[0])
+	if err != nil {
+		sendShareErr(w, err, "cannot revoke share")
+		return
+	}
+
+	rest.sendTree(w, r, collection, "share revoked successfully")
+}
+
+// listShares returns all shares of the caller
+func (rest *Rest) listShares(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	if !authorize(w, r, store.ScopeShare) {
+		return
+	}
+
+	shares, err := rest.Store.ListShares(collection)
+	if err != nil {
+		sendErrStatus(w, err, "cannot list shares", http.StatusInternalServerError)
+		return
+	}
+
+	// API key sees only shares inside its prefix
+	visible := make([]*store.ShareInfo, 0, len(shares))
+	for _, share := range shares {
+		if covers(r, share.Path) {
+			visible = append(visible, share)
+		}
+	}
+
+	sendJSON(w, visible)
+}
+
+// getShare returns single share of the caller
+func (rest *Rest) getShare(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	if !rest.authorizeShare(w, r, collection, mux.Vars(r)["token"]) {
+		return
+	}
+
+	share, err := rest.Store.GetShare(collection, mux.Vars(r)["token"])
+	if err != nil {
+		sendShareErr(w, err, "cannot get share")
+		return
+	}
+
+	sendJSON(w, share)
+}
+
+// revokeShare removes share of the caller
+func (rest *Rest) revokeShare(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	if !rest.authorizeShare(w, r, collection, mux.Vars(r)["token"]) {
+		return
+	}
+
+	err := rest.Store.RevokeShare(collection, mux.Vars(r)["token"])
+	if err != nil {
+		sendShareErr(w, err, "cannot revoke share")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// shareOptions reads share restrictions from request
+func shareOptions(r *http.Request) (store.ShareOptions, error) {
+	opts := store.ShareOptions{
+		Password: r.Header.Get(sharePasswordHeader),
+		Mode:     r.URL.Query().Get("mode"),
+	}
+
+	switch opts.Mode {
+	case "", store.ShareSnapshot, store.ShareLive, store.ShareDropBox, store.ShareSite:
+	default:
+		return opts, errors.Errorf("unknown share mode \"%s\"", opts.Mode)
+	}
+
+	expires, err := parseExpires(r.URL.Query().Get("expires"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Expires = expires
+
+	if maxDownloads := r.URL.Query().Get("max-downloads"); maxDownloads != "" {
+		n, err := strconv.Atoi(maxDownloads)
+		if err != nil || n < 0 {
+			return opts, errors.Errorf("invalid download limit \"%s\"", maxDownloads)
+		}
+		opts.MaxDownloads = n
+	}
+
+	if maxSize := r.URL.Query().Get("max-size"); maxSize != "" {
+		n, err := strconv.ParseInt(maxSize, 10, 64)
+		if err != nil || n < 0 {
+			return opts, errors.Errorf("invalid size limit \"%s\"", maxSize)
+		}
+		opts.MaxSize = n
+	}
+
+	if maxUploads := r.URL.Query().Get("max-uploads"); maxUploads != "" {
+		n, err := strconv.Atoi(maxUploads)
+		if err != nil || n < 0 {
+			return opts, errors.Errorf("invalid upload limit \"%s\"", maxUploads)
+		}
+		opts.MaxUploads = n
+	}
+
+	return opts, nil
+}
+
+// sendShareErr maps store share and mount errors to status codes
+// errors not related to shares are sent with given message and 200 status, like sendErr does
+func sendShareErr(w http.ResponseWriter, err error, msg string) {
+	sendShareErrStatus(w, err, msg, http.StatusOK)
+}
+
+// sendShareErrStatus works like sendShareErr, but sends other errors with given status
+func sendShareErrStatus(w http.ResponseWriter, err error, msg string, status int) {
+	switch errors.Cause(err) {
+	case store.ErrShareNotFound:
+		sendErrStatus(w, err, "share not found", http.StatusNotFound)
+	case store.ErrShareExpired:
+		sendErrStatus(w, err, "share expired", http.StatusGone)
+	case store.ErrShareExhausted:
+		sendErrStatus(w, err, "share download limit reached", http.StatusGone)
+	case store.ErrShareSourceGone:
+		sendErrStatus(w, err, "shared source no longer exists", http.StatusGone)
+	case store.ErrShareForbidden:
+		sendErrStatus(w, err, "share does not allow reading", http.StatusForbidden)
+	case store.ErrDropBoxFull:
+		sendErrStatus(w, err, "drop box upload limit reached", http.StatusForbidden)
+	case store.ErrDropTooLarge:
+		sendErrStatus(w, err, "file exceeds drop box size limit", http.StatusRequestEntityTooLarge)
+	case store.ErrInvalidPath:
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+	case store.ErrAliasNotFound:
+		sendErrStatus(w, err, "alias not found", http.StatusNotFound)
+	case store.ErrAliasTaken:
+		sendErrStatus(w, err, "alias already taken", http.StatusConflict)
+	case store.ErrInvalidAlias:
+		sendErrStatus(w, err, "invalid alias", http.StatusBadRequest)
+	case store.ErrSitePageNotFound:
+		sendErrStatus(w, err, "page not found", http.StatusNotFound)
+	case store.ErrMountNotFound:
+		sendErrStatus(w, err, "mount not found", http.StatusNotFound)
+	case store.ErrMountReadOnly:
+		sendErrStatus(w, err, "mounted share is read-only", http.StatusForbidden)
+	case store.ErrMountBroken:
+		sendErrStatus(w, err, "mounted share no longer exists", http.StatusGone)
+	case store.ErrSharePassword:
+		w.Header().Set("WWW-Authenticate", sharePasswordHeader)
+		sendErrStatus(w, err, "invalid share password", http.StatusUnauthorized)
+	default:
+		sendErrStatus(w, err, msg, status)
+	}
+}
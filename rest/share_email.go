@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+)
+
+// defaults for rate limiting of share emails
+const (
+	defaultShareEmailLimit  = 20
+	defaultShareEmailWindow = time.Hour
+)
+
+// delivery statuses of share email
+const (
+	emailSent        = "sent"
+	emailFailed      = "failed"
+	emailInvalid     = "invalid"
+	emailRateLimited = "rate_limited"
+)
+
+type shareEmailRequest struct {
+	Recipients []string `json:"recipients"`
+	// Message is optional text, which is put before the link
+	Message string `json:"message"`
+}
+
+// emailShare sends absolute link to share of the caller to list of recipients
+// every user could send limited number of emails per window, recipients over the limit are not sent
+// responds with delivery status of every recipient, statuses are also kept in share info
+func (rest *Rest) emailShare(w http.ResponseWriter, r *http.Request) {
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return
+	}
+	if !rest.authorizeShare(w, r, collection, mux.Vars(r)["token"]) {
+		return
+	}
+
+	req := &shareEmailRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		sendErrStatus(w, err, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	recipients := uniqueRecipients(req.Recipients)
+	if len(recipients) == 0 {
+		sendErrStatus(w, nil, "recipients should be provided", http.StatusBadRequest)
+		return
+	}
+
+	share, err := rest.Store.GetShare(collection, mux.Vars(r)["token"])
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot get share", http.StatusInternalServerError)
+		return
+	}
+
+	link, ok := rest.emailURL(w)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if retry := rest.emailLimiter.retryAfter(collection, now); retry > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+		sendErrStatus(w, nil, "too many emails, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	body := shareEmailBody(share, link+sharedPath+"/"+url.PathEscape(share.Token), req.Message)
+	statuses := make([]store.ShareEmail, 0, len(recipients))
+	for _, recipient := range recipients {
+		status := store.ShareEmail{Email: recipient, Sent: now}
+		switch address, err := mail.ParseAddress(recipient); {
+		case err != nil:
+			status.Status = emailInvalid
+			status.Error = err.Error()
+		case !rest.emailLimiter.allow(collection, now):
+			status.Status = emailRateLimited
+		default:
+			if _, err := rest.Email.Send(address.Address, body); err != nil {
+				status.Status = emailFailed
+				status.Error = err.Error()
+			} else {
+				status.Status = emailSent
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	if err := rest.Store.AddShareEmails(collection, share.Token, statuses); err != nil {
+		sendErrStatus(w, err, "emails sent, but cannot save delivery status", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, statuses)
+}
+
+// emailURL returns configured base of links sent by email
+// links in emails are never built from request, because Host header is controlled by client,
+// so anyone could make server email links to other host; emails are refused when PublicURL is not set
+func (rest *Rest) emailURL(w http.ResponseWriter) (string, bool) {
+	if rest.PublicURL == "" {
+		sendErrStatus(w, nil, "emails are disabled, PUBLIC_URL is not configured", http.StatusServiceUnavailable)
+		return "", false
+	}
+
+	return strings.TrimSuffix(rest.PublicURL, "/"), true
+}
+
+// publicURL returns base of absolute links returned to the caller itself
+// configured url is used when set, otherwise it is built from request
+func (rest *Rest) publicURL(r *http.Request) string {
+	if rest.PublicURL != "" {
+		return strings.TrimSuffix(rest.PublicURL, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// shareEmailBody creates text of share email
+func shareEmailBody(share *store.ShareInfo, link string, message string) string {
+	body := ""
+	if message != "" {
+		body += message + "\n\n"
+	}
+
+	if share.Mode == store.ShareDropBox {
+		body += "You are invited to upload files: POST " + link + "/<file name>\n"
+	} else {
+		body += "Files were shared with you: " + link + "\n"
+	}
+	if share.Protected {
+		body += "The link is protected with password, ask sender for it.\n"
+	}
+	if share.Expires != nil {
+		body += "The link expires at " + share.Expires.UTC().Format(time.RFC1123) + ".\n"
+	}
+
+	return body
+}
+
+// uniqueRecipients trims addresses and drops empty and repeated ones
+func uniqueRecipients(recipients []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" || seen[strings.ToLower(recipient)] {
+			continue
+		}
+		seen[strings.ToLower(recipient)] = true
+		unique = append(unique, recipient)
+	}
+
+	return unique
+}
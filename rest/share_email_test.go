@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEmail remembers sent emails, addresses starting with "fail" are rejected
+type recordingEmail struct {
+	sent map[string]string
+}
+
+func (e *recordingEmail) Send(targetEmail, msgBody string) (string, error) {
+	if strings.HasPrefix(targetEmail, "fail") {
+		return "", errors.New("mailbox unavailable")
+	}
+	e.sent[targetEmail] = msgBody
+	return "OK", nil
+}
+
+func TestEmailShare(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	mailer := &recordingEmail{sent: make(map[string]string)}
+	r.Email = mailer
+	r.PublicURL = "https://dbfs.example.com/"
+	r.ShareEmailLimit = 3
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"me"}, "mine", store.ShareOptions{Expires: expires}))
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	send := func(token string, body string) (int, string) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+sharesPath+"/"+token+"/email", strings.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+
+	status, msg := send("foreign", `{"recipients": ["a@mail.com"]}`)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "share not found", msg)
+
+	status, msg = send("mine", `{"recipients": []}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "recipients should be provided", msg)
+
+	status, msg = send("mine", `{"recipients": ["a@mail.com", "A@mail.com", "not an address", "fail@mail.com", "b@mail.com", "c@mail.com"], "message": "look at this"}`)
+	require.Equal(t, http.StatusOK, status)
+	statuses := make([]store.ShareEmail, 0)
+	require.Nil(t, json.Unmarshal([]byte(msg), &statuses))
+
+	expected := map[string]string{
+		"a@mail.com":     emailSent,
+		"not an address": emailInvalid,
+		"fail@mail.com":  emailFailed,
+		"b@mail.com":     emailSent,
+		"c@mail.com":     emailRateLimited,
+	}
+	require.Len(t, statuses, len(expected))
+	for _, s := range statuses {
+		assert.Equal(t, expected[s.Email], s.Status, s.Email)
+	}
+
+	assert.Equal(t, "look at this\n\n"+
+		"Files were shared with you: https://dbfs.example.com/shared/mine\n"+
+		"The link expires at Wed, 02 Jan 2030 03:04:05 UTC.\n", mailer.sent["a@mail.com"])
+
+	// statuses are kept in share info
+	share, err := r.Store.GetShare(defaultCollection, "mine")
+	require.Nil(t, err)
+	assert.Len(t, share.Emails, len(expected))
+
+	// limit is reached
+	status, msg = send("mine", `{"recipients": ["d@mail.com"]}`)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "too many emails, try again later", msg)
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, time.Minute)
+	now := time.Now()
+
+	assert.True(t, l.allow("a", now))
+	assert.True(t, l.allow("a", now.Add(10*time.Second)))
+	assert.False(t, l.allow("a", now.Add(20*time.Second)))
+	assert.True(t, l.allow("b", now.Add(20*time.Second)))
+	assert.Equal(t, 40*time.Second, l.retryAfter("a", now.Add(20*time.Second)))
+
+	assert.True(t, l.allow("a", now.Add(time.Minute)))
+	assert.False(t, l.allow("a", now.Add(time.Minute)))
+}
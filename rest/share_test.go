@@ -0,0 +1,286 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareManagement(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	require.Nil(t, r.Store.Create("other"))
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"me"}, "mine", store.ShareOptions{}))
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"must"}, "revoked", store.ShareOptions{}))
+	require.Nil(t, r.Store.Share("other", []string{}, "foreign", store.ShareOptions{}))
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	tt := []struct {
+		Method       string
+		URL          string
+		Token        string
+		Status       int
+		ResponseBody string
+	}{
+		{http.MethodDelete, sharedPath + "/revoked", "", http.StatusUnauthorized, "empty Authorization header"},
+		{http.MethodDelete, sharedPath + "/foreign", defaultCollection, http.StatusNotFound, "share not found"},
+		{http.MethodDelete, sharedPath + "/revoked", defaultCollection, http.StatusOK, "Neo\nanswer\nme\n  and\nmust\n  have\n    been\n      like\n/shared\n  mine\n    me\n      and\n"},
+		{http.MethodGet, sharesPath + "/foreign", defaultCollection, http.StatusNotFound, "share not found"},
+		{http.MethodDelete, sharesPath + "/foreign", defaultCollection, http.StatusNotFound, "share not found"},
+		{http.MethodGet, sharedPath + "/" + defaultCollection + "/answer/x", "", http.StatusNotFound, "share not found"},
+	}
+
+	for _, test := range tt {
+		req, err := http.NewRequest(test.Method, ts.URL+test.URL, nil)
+		require.Nil(t, err)
+		req.Header.Set("Authorization", test.Token)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.Status, resp.StatusCode)
+		assert.Equal(t, test.ResponseBody, string(msg))
+	}
+
+	// list
+	req, err := http.NewRequest(http.MethodGet, ts.URL+sharesPath, nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+
+	shares := make([]*store.ShareInfo, 0)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&shares))
+	require.Equal(t, 1, len(shares))
+	assert.Equal(t, "mine", shares[0].Token)
+	assert.Equal(t, []string{"me"}, shares[0].Path)
+
+	// revoke
+	req, err = http.NewRequest(http.MethodDelete, ts.URL+sharesPath+"/mine", nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	shares, err = r.Store.ListShares(defaultCollection)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(shares))
+}
+
+func TestShareRestrictions(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	share := func(query string, password string) string {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+sharePath+"/me?"+query, nil)
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+		req.Header.Set(sharePasswordHeader, password)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		token, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return string(token)
+	}
+
+	limited := share("max-downloads=1", "")
+	protected := share("", "secret")
+	expired := share("expires=2000-01-01T00:00:00Z", "")
+	assert.Equal(t, "invalid share options", share("expires=tomorrow", ""))
+
+	// folder listings are not counted as downloads
+	tt := []struct {
+		Token        string
+		Path         string
+		Password     string
+		Status       int
+		ResponseBody string
+	}{
+		{limited, "", "", http.StatusOK, "and\n"},
+		{limited, "", "", http.StatusOK, "and\n"},
+		{limited, "/and", "", http.StatusOK, "The Boys"},
+		{limited, "/and", "", http.StatusGone, "share download limit reached"},
+		{limited, "", "", http.StatusGone, "share download limit reached"},
+		{protected, "", "", http.StatusUnauthorized, "invalid share password"},
+		{protected, "", "secret", http.StatusOK, "and\n"},
+		{expired, "", "", http.StatusGone, "share expired"},
+	}
+
+	for _, test := range tt {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+sharedPath+"/"+test.Token+"/me"+test.Path, nil)
+		require.Nil(t, err)
+		req.Header.Set(sharePasswordHeader, test.Password)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.Status, resp.StatusCode)
+		assert.Equal(t, test.ResponseBody, string(msg))
+	}
+}
+
+func TestLiveShare(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+sharePath+"/me?mode=live", nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	token, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+
+	read := func() (int, string) {
+		resp, err := http.Get(ts.URL + sharedPath + "/" + string(token) + "/me")
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+
+	status, msg := read()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "and\n", msg)
+
+	require.Nil(t, r.Store.Put(defaultCollection, []string{"me", "too"}, strings.NewReader("")))
+	status, msg = read()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "and\ntoo\n", msg)
+
+	require.Nil(t, r.Store.Delete(defaultCollection, []string{"me"}))
+	status, msg = read()
+	assert.Equal(t, http.StatusGone, status)
+	assert.Equal(t, "shared source no longer exists", msg)
+}
+
+func TestDropBox(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+sharePath+"/me?mode=dropbox&max-size=4&max-uploads=2", nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	token, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+
+	tt := []struct {
+		Path   string
+		Data   string
+		Status int
+		Result string
+	}{
+		{"/in.txt", "one", http.StatusCreated, "in.txt"},
+		{"/in.txt", "large", http.StatusRequestEntityTooLarge, "file exceeds drop box size limit"},
+		{"/in.txt", "two", http.StatusCreated, "in (1).txt"},
+		{"/in.txt", "more", http.StatusForbidden, "drop box upload limit reached"},
+		{"", "none", http.StatusBadRequest, "share token and file name should be provided"},
+		{"/a/b", "deep", http.StatusBadRequest, "share token and file name should be provided"},
+	}
+
+	for _, test := range tt {
+		resp, err := http.Post(ts.URL+sharedPath+"/"+string(token)+test.Path, "", strings.NewReader(test.Data))
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		assert.Equal(t, test.Status, resp.StatusCode, test.Path)
+		assert.Equal(t, test.Result, string(msg))
+	}
+
+	// listing and download are denied
+	resp, err = http.Get(ts.URL + sharedPath + "/" + string(token) + "/me/in.txt")
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	b, err := r.Store.Get(defaultCollection, []string{"me", "in (1).txt"})
+	require.Nil(t, err)
+	assert.Equal(t, "two", string(b))
+
+	share, err := r.Store.GetShare(defaultCollection, string(token))
+	require.Nil(t, err)
+	require.Len(t, share.Uploads, 2)
+	assert.Equal(t, "127.0.0.1", share.Uploads[0].IP)
+}
+
+func TestFileShare(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	require.Nil(t, r.Store.Put(defaultCollection, []string{"docs", "report.pdf"}, strings.NewReader("%PDF")))
+	require.Nil(t, r.Store.Put(defaultCollection, []string{"docs", "deep", "notes"}, strings.NewReader("plain notes")))
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	share := func(path string) string {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+sharePath+path, nil)
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		token, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return string(token)
+	}
+
+	file := share("/docs/report.pdf")
+	folder := share("/docs")
+
+	tt := []struct {
+		Path        string
+		Body        string
+		ContentType string
+		Disposition string
+	}{
+		{"/" + file, "%PDF", "application/pdf", "attachment; filename=report.pdf"},
+		{"/" + file + "/report.pdf", "%PDF", "application/pdf", "attachment; filename=report.pdf"},
+		{"/" + folder, "docs\n  deep\n    notes\n  report.pdf\n", "text/plain; charset=utf-8", ""},
+		{"/" + folder + "/docs/deep", "notes\n", "text/plain; charset=utf-8", ""},
+		{"/" + folder + "/docs/deep/notes", "plain notes", "text/plain; charset=utf-8", "attachment; filename=notes"},
+	}
+
+	for _, test := range tt {
+		resp, err := http.Get(ts.URL + sharedPath + test.Path)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, test.Body, string(msg))
+		assert.Equal(t, test.ContentType, resp.Header.Get("Content-Type"))
+		assert.Equal(t, test.Disposition, resp.Header.Get("Content-Disposition"))
+	}
+}
@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+const defaultSignupTTL = 30 * time.Minute
+
+// verify finishes registration started by register and creates account
+// token is shown in response and also sent by email, because links could be opened by mail scanners
+func (rest *Rest) verify(w http.ResponseWriter, r *http.Request) {
+	id, expires, err := rest.parseSignupCode(mux.Vars(r)["code"])
+	if err != nil {
+		sendErrStatus(w, err, "verification link is invalid or already used", http.StatusNotFound)
+		return
+	}
+	if time.Now().After(expires) {
+		sendErrStatus(w, nil, "verification link expired, register again", http.StatusGone)
+		return
+	}
+
+	token := newToken()
+	account, err := rest.Store.ConfirmSignup(id, randomToken(16), token)
+	switch errors.Cause(err) {
+	case nil:
+	case store.ErrSignupNotFound:
+		sendErrStatus(w, err, "verification link is invalid or already used", http.StatusNotFound)
+		return
+	case store.ErrSignupExpired:
+		sendErrStatus(w, err, "verification link expired, register again", http.StatusGone)
+		return
+	case store.ErrAccountExists:
+		sendErrStatus(w, err, "email is already registered, use /recover to get new token", http.StatusConflict)
+		return
+	case store.ErrInviteNotFound, store.ErrInviteExpired, store.ErrInviteExhausted:
+		sendInviteErr(w, err)
+		return
+	default:
+		sendErrStatus(w, err, "cannot register", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := rest.Email.Send(account.Email, token); err != nil {
+		log.Printf("[ERROR] %s", errors.Wrap(err, "cannot send email with token"))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("registration successful. your token (also sent by email):\n" + token + "\n"))
+}
+
+// signupCode creates verification code in form of "<signup id>.<expiration unix time>.<signature>"
+// signature lets to reject forged and expired codes without touching database
+func (rest *Rest) signupCode(signup *store.Signup) string {
+	payload := signup.ID + "." + strconv.FormatInt(signup.Expires.Unix(), 10)
+	return payload + "." + rest.sign("signup:"+payload)
+}
+
+// parseSignupCode checks signature of verification code and returns signup ID with expiration time
+func (rest *Rest) parseSignupCode(code string) (string, time.Time, error) {
+	parts := strings.Split(code, ".")
+	if len(parts) != 3 {
+		return "", time.Time{}, errors.New("malformed verification code")
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !rest.verifySignature("signup:"+payload, parts[2]) {
+		return "", time.Time{}, errors.New("invalid signature of verification code")
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "invalid expiration of verification code")
+	}
+	return parts[0], time.Unix(expires, 0), nil
+}
+
+// sign returns hex encoded HMAC-SHA256 of payload
+// payloads of different purposes should have different prefixes, so signatures could not be reused
+func (rest *Rest) sign(payload string) string {
+	mac := hmac.New(sha256.New, rest.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks signature made by sign in constant time
+func (rest *Rest) verifySignature(payload string, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, rest.secret)
+	mac.Write([]byte(payload))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerAccount goes through registration with email verification and returns token of new account
+func registerAccount(t *testing.T, ts *httptest.Server, mailer *recordingEmail, email string) string {
+	resp, err := http.Post(ts.URL+"/register", "application/json", strings.NewReader(`{"email": "`+email+`"}`))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// link is built from PublicURL, so only its path is used
+	link := verifyLink(t, mailer.sent[email])
+	resp, err = http.Get(ts.URL + link[strings.Index(link, verifyPath+"/"):])
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	return mailer.sent[email]
+}
+
+// verifyLink finds verification link in email body
+func verifyLink(t *testing.T, body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.Contains(line, verifyPath+"/") {
+			return strings.TrimSpace(line)
+		}
+	}
+	require.Fail(t, "verification link not found", body)
+	return ""
+}
+
+func TestSignup(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	mailer := &recordingEmail{sent: make(map[string]string)}
+	r.Email = mailer
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+	r.PublicURL = ts.URL
+
+	register := func(email string) (int, string) {
+		resp, err := http.Post(ts.URL+"/register", "application/json", strings.NewReader(`{"email": "`+email+`"}`))
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+	get := func(url string) (int, string) {
+		resp, err := http.Get(url)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(msg)
+	}
+
+	status, msg := register("random@gmail.com")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "verification link sent. check email", msg)
+	first := verifyLink(t, mailer.sent["random@gmail.com"])
+
+	// repeated registration replaces the link
+	status, _ = register("random@gmail.com")
+	assert.Equal(t, http.StatusOK, status)
+	second := verifyLink(t, mailer.sent["random@gmail.com"])
+	assert.NotEqual(t, first, second)
+
+	// last hex digit of signature is always changed
+	tamper := func(link string) string {
+		last := "0"
+		if strings.HasSuffix(link, "0") {
+			last = "1"
+		}
+		return link[:len(link)-1] + last
+	}
+
+	tt := []struct {
+		URL    string
+		Status int
+		Body   string
+	}{
+		{first, http.StatusNotFound, "verification link is invalid or already used"},
+		{tamper(second), http.StatusNotFound, "verification link is invalid or already used"},
+		{ts.URL + verifyPath + "/garbage", http.StatusNotFound, "verification link is invalid or already used"},
+	}
+	for _, test := range tt {
+		status, msg := get(test.URL)
+		assert.Equal(t, test.Status, status, test.URL)
+		assert.Equal(t, test.Body, msg)
+	}
+
+	status, msg = get(second)
+	require.Equal(t, http.StatusOK, status)
+	token := mailer.sent["random@gmail.com"]
+	assert.Equal(t, "registration successful. your token (also sent by email):\n"+token+"\n", msg)
+	account, _, err := r.Store.Authenticate(token)
+	require.Nil(t, err)
+	assert.Equal(t, "random@gmail.com", account.Email)
+
+	// link works once, registered email gets the same response, but is pointed to recovery
+	status, _ = get(second)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, msg = register("random@gmail.com")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "verification link sent. check email", msg)
+	assert.Contains(t, mailer.sent["random@gmail.com"], "you already have dbfs account")
+	assert.Contains(t, mailer.sent["random@gmail.com"], ts.URL+recoverPath)
+	count, err := r.Store.ListAccounts()
+	require.Nil(t, err)
+	assert.Len(t, count, 2)
+
+	// link is never built from Host header of request
+	r.PublicURL = ""
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/register", strings.NewReader(`{"email": "myEpicEmail@gmail.com"}`))
+	require.Nil(t, err)
+	req.Host = "evil.example"
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Empty(t, mailer.sent["myEpicEmail@gmail.com"])
+	r.PublicURL = ts.URL
+
+	// expired links
+	r.SignupTTL = -time.Minute
+	status, _ = register("myEpicEmail@gmail.com")
+	require.Equal(t, http.StatusOK, status)
+	status, msg = get(verifyLink(t, mailer.sent["myEpicEmail@gmail.com"]))
+	assert.Equal(t, http.StatusGone, status)
+	assert.Equal(t, "verification link expired, register again", msg)
+}
@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const defaultSiteMaxAge = 5 * time.Minute
+
+// siteRoutes maps serving of folders shared in site mode
+func (rest *Rest) siteRoutes(router *mux.Router) {
+	siteSubrouter := router.PathPrefix(sitePath).Subrouter()
+	siteSubrouter.Use(rest.stripPrefix(sitePath))
+	siteSubrouter.PathPrefix("").HandlerFunc(rest.site).Methods("GET", "HEAD")
+}
+
+// site serves page of site share, path starts with share token
+// folders are served by index.html, folder urls without trailing slash are redirected,
+// so relative links of index page point inside the folder
+func (rest *Rest) site(w http.ResponseWriter, r *http.Request) {
+	keys, err := rest.parsePath(r)
+	if err != nil {
+		sendErrStatus(w, err, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if len(keys) == 0 {
+		sendErrStatus(w, nil, "share token should be provided", http.StatusBadRequest)
+		return
+	}
+
+	password := r.Header.Get(sharePasswordHeader)
+	page, err := rest.Store.GetSite(keys[0], password, keys[1:])
+	if err != nil {
+		sendShareErrStatus(w, err, "cannot get page", http.StatusInternalServerError)
+		return
+	}
+
+	if page.Index && !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, sitePath+r.URL.EscapedPath()+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(page.Name))
+	if contentType == "" {
+		contentType = http.DetectContentType(page.Data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(page.Data)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	status := http.StatusOK
+	if page.NotFound {
+		// missing page could be created later
+		w.Header().Set("Cache-Control", "no-cache")
+		status = http.StatusNotFound
+	} else {
+		maxAge := rest.SiteMaxAge
+		if maxAge == 0 {
+			maxAge = defaultSiteMaxAge
+		}
+		// pages of protected site should not be kept by shared caches
+		visibility := "public"
+		if password != "" {
+			visibility = "private"
+		}
+
+		etag := fmt.Sprintf("\"%x\"", sha256.Sum256(page.Data))
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(maxAge.Seconds())))
+		if r.Header.Get("If-None-Match") == etag {
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.WriteHeader(status)
+	if _, err := w.Write(page.Data); err != nil {
+		log.Println(err)
+	}
+}
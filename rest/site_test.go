@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mind-rot/dbfs/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSite(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	require.Nil(t, r.Store.Put(defaultCollection, []string{"www", "index.html"}, strings.NewReader(`<a href="docs/">docs</a>`)))
+	require.Nil(t, r.Store.Put(defaultCollection, []string{"www", "docs", "index.html"}, strings.NewReader(`<link href="style.css">`)))
+	require.Nil(t, r.Store.Put(defaultCollection, []string{"www", "docs", "style.css"}, strings.NewReader("body {}")))
+	require.Nil(t, r.Store.Put(defaultCollection, []string{"www", "empty", "file"}, strings.NewReader("")))
+	require.Nil(t, r.Store.Share(defaultCollection, []string{"www"}, "site", store.ShareOptions{Mode: store.ShareSite}))
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	// redirects are checked separately
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	tt := []struct {
+		Path        string
+		Status      int
+		ContentType string
+		Body        string
+	}{
+		{"/site/", http.StatusOK, "text/html; charset=utf-8", `<a href="docs/">docs</a>`},
+		{"/site", http.StatusMovedPermanently, "text/html; charset=utf-8", "<a href=\"/site/site/\">Moved Permanently</a>.\n\n"},
+		{"/site/docs", http.StatusMovedPermanently, "text/html; charset=utf-8", "<a href=\"/site/site/docs/\">Moved Permanently</a>.\n\n"},
+		{"/site/docs/", http.StatusOK, "text/html; charset=utf-8", `<link href="style.css">`},
+		{"/site/docs/style.css", http.StatusOK, "text/css; charset=utf-8", "body {}"},
+		{"/site/empty/", http.StatusNotFound, "", "page not found"},
+		{"/missing/", http.StatusNotFound, "", "share not found"},
+	}
+
+	for _, test := range tt {
+		resp, err := client.Get(ts.URL + sitePath + test.Path)
+		require.Nil(t, err)
+		msg, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, test.Status, resp.StatusCode, test.Path)
+		assert.Equal(t, test.Body, string(msg), test.Path)
+		if test.ContentType != "" {
+			assert.Equal(t, test.ContentType, resp.Header.Get("Content-Type"), test.Path)
+		}
+	}
+
+	// cache headers
+	resp, err := http.Get(ts.URL + sitePath + "/site/docs/style.css")
+	require.Nil(t, err)
+	assert.Equal(t, "public, max-age=300", resp.Header.Get("Cache-Control"))
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+sitePath+"/site/docs/style.css", nil)
+	require.Nil(t, err)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// custom 404 page
+	require.Nil(t, r.Store.Put(defaultCollection, []string{"www", "404.html"}, strings.NewReader("nothing here")))
+	resp, err = http.Get(ts.URL + sitePath + "/site/empty/")
+	require.Nil(t, err)
+	msg, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "nothing here", string(msg))
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+}
@@ -0,0 +1,252 @@
+package rest
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mind-rot/dbfs/store"
+	"github.com/pkg/errors"
+)
+
+// tus protocol constants (https://tus.io/protocols/resumable-upload.html)
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	tusOctets     = "application/offset+octet-stream"
+
+	defaultUploadTTL = 24 * time.Hour
+)
+
+// uploadRoutes maps tus core protocol with creation, termination and expiration extensions
+func (rest *Rest) uploadRoutes(router *mux.Router) {
+	router.HandleFunc(uploadsPath, rest.uploadOptions).Methods("OPTIONS")
+	router.HandleFunc(uploadsPath, rest.createUpload).Methods("POST")
+	router.HandleFunc(uploadsPath+"/{id}", rest.uploadOffset).Methods("HEAD")
+	router.HandleFunc(uploadsPath+"/{id}", rest.patchUpload).Methods("PATCH")
+	router.HandleFunc(uploadsPath+"/{id}", rest.deleteUpload).Methods("DELETE")
+}
+
+// uploadOptions describes supported protocol version and extensions
+func (rest *Rest) uploadOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	if rest.MaxUploadSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(rest.MaxUploadSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createUpload starts new upload
+// target path is taken from "path" (or "filename") key of Upload-Metadata header
+// path is escaped the same way as request urls, so names with "/" should be sent as "%2F"
+func (rest *Rest) createUpload(w http.ResponseWriter, r *http.Request) {
+	token, ok := rest.tusRequest(w, r)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		sendErrStatus(w, err, "invalid Upload-Length header", http.StatusBadRequest)
+		return
+	}
+	if rest.MaxUploadSize > 0 && length > rest.MaxUploadSize {
+		sendErrStatus(w, nil, "upload is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		sendErrStatus(w, err, "invalid Upload-Metadata header", http.StatusBadRequest)
+		return
+	}
+	target := metadata["path"]
+	if target == "" {
+		target = metadata["filename"]
+	}
+	keys, err := rest.Store.ParsePath(target)
+	if err != nil {
+		sendErrStatus(w, err, "invalid upload path", http.StatusBadRequest)
+		return
+	}
+	if len(keys) == 0 {
+		sendErrStatus(w, nil, "upload path should be provided", http.StatusBadRequest)
+		return
+	}
+	if !authorizePath(w, r, store.ScopeWrite, keys) {
+		return
+	}
+
+	ttl := rest.UploadTTL
+	if ttl == 0 {
+		ttl = defaultUploadTTL
+	}
+
+	upload, err := rest.Store.CreateUpload(token, keys, randomToken(16), length, time.Now().Add(ttl))
+	if err != nil {
+		sendErrStatus(w, err, "cannot create upload", http.StatusBadRequest)
+		return
+	}
+
+	// empty file does not need any PATCH requests
+	if upload.Done() {
+		upload, err = rest.Store.WriteUpload(token, upload.ID, 0, strings.NewReader(""))
+		if err != nil {
+			sendErrStatus(w, err, "cannot create upload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Location", uploadsPath+"/"+upload.ID)
+	w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// uploadOffset returns how many bytes of upload were received
+func (rest *Rest) uploadOffset(w http.ResponseWriter, r *http.Request) {
+	token, ok := rest.tusRequest(w, r)
+	if !ok {
+		return
+	}
+
+	upload, err := rest.Store.GetUpload(token, mux.Vars(r)["id"])
+	if err != nil {
+		sendUploadErr(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+// patchUpload writes chunk of data at the given offset
+func (rest *Rest) patchUpload(w http.ResponseWriter, r *http.Request) {
+	token, ok := rest.tusRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("Content-Type") != tusOctets {
+		sendErrStatus(w, nil, "Content-Type should be "+tusOctets, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		sendErrStatus(w, err, "invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := rest.Store.WriteUpload(token, mux.Vars(r)["id"], offset, r.Body)
+	if err != nil {
+		sendUploadErr(w, err)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if !upload.Done() {
+		w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteUpload terminates upload
+func (rest *Rest) deleteUpload(w http.ResponseWriter, r *http.Request) {
+	token, ok := rest.tusRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := rest.Store.DeleteUpload(token, mux.Vars(r)["id"])
+	if err != nil {
+		sendUploadErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tusRequest checks protocol version and authorization of tus request
+// returns collection of the caller, when request could be processed
+func (rest *Rest) tusRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		sendErrStatus(w, nil, "unsupported tus version", http.StatusPreconditionFailed)
+		return "", false
+	}
+
+	collection := accountID(r)
+	if collection == "" {
+		sendErrStatus(w, nil, "empty Authorization header", http.StatusUnauthorized)
+		return "", false
+	}
+	if !authorize(w, r, store.ScopeWrite) {
+		return "", false
+	}
+
+	// uploads started by other tokens could be continued only when their target is inside key prefix
+	if id := mux.Vars(r)["id"]; id != "" && apiKey(r) != nil {
+		upload, err := rest.Store.GetUpload(collection, id)
+		if err != nil {
+			sendUploadErr(w, err)
+			return "", false
+		}
+		if !covers(r, upload.Keys) {
+			sendErrStatus(w, nil, "path is outside of token prefix", http.StatusForbidden)
+			return "", false
+		}
+	}
+
+	return collection, true
+}
+
+// sendUploadErr maps store upload errors to protocol status codes
+func sendUploadErr(w http.ResponseWriter, err error) {
+	switch errors.Cause(err) {
+	case store.ErrUploadNotFound:
+		sendErrStatus(w, err, "upload not found", http.StatusNotFound)
+	case store.ErrUploadExpired:
+		sendErrStatus(w, err, "upload expired", http.StatusGone)
+	case store.ErrUploadOffset:
+		sendErrStatus(w, err, "upload offset mismatch", http.StatusConflict)
+	case store.ErrUploadTooLarge:
+		sendErrStatus(w, err, "upload exceeds declared length", http.StatusRequestEntityTooLarge)
+	default:
+		sendErrStatus(w, err, "cannot write upload", http.StatusInternalServerError)
+	}
+}
+
+// parseUploadMetadata decodes Upload-Metadata header
+// header consists of comma separated pairs of key and base64 encoded value
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid value of \"%s\" key", fields[0])
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, errors.Errorf("invalid metadata pair \"%s\"", pair)
+		}
+	}
+
+	return metadata, nil
+}
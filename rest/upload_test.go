@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpload(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	tusRequest := func(method, url string, body string, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", defaultCollection)
+		req.Header.Set("Tus-Resumable", tusVersion)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		return resp
+	}
+
+	// creation
+	resp := tusRequest(http.MethodPost, ts.URL+uploadsPath, "", map[string]string{
+		"Upload-Length":   "11",
+		"Upload-Metadata": "path " + base64.StdEncoding.EncodeToString([]byte("uploaded/file")),
+	})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	location := ts.URL + resp.Header.Get("Location")
+
+	// chunks
+	tt := []struct {
+		Offset string
+		Chunk  string
+		Status int
+		Result string
+	}{
+		{"0", "Hello ", http.StatusNoContent, "6"},
+		{"0", "Hello ", http.StatusConflict, ""},
+		{"6", "there", http.StatusNoContent, "11"},
+	}
+
+	for _, test := range tt {
+		resp := tusRequest(http.MethodPatch, location, test.Chunk, map[string]string{
+			"Upload-Offset": test.Offset,
+			"Content-Type":  tusOctets,
+		})
+		assert.Equal(t, test.Status, resp.StatusCode)
+		assert.Equal(t, test.Result, resp.Header.Get("Upload-Offset"))
+	}
+
+	// finished upload is committed and no longer available
+	resp = tusRequest(http.MethodHead, location, "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	b, err := r.Store.Get(defaultCollection, []string{"uploaded", "file"})
+	require.Nil(t, err)
+	assert.Equal(t, "Hello there", string(b))
+}
+
+func TestUploadProtocol(t *testing.T) {
+	r, err := getRest()
+	require.Nil(t, err)
+	defer r.Store.Drop()
+
+	ts := httptest.NewServer(r.Router())
+	defer ts.Close()
+
+	// unsupported version
+	req, err := http.NewRequest(http.MethodPost, ts.URL+uploadsPath, nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	req.Header.Set("Tus-Resumable", "0.2.2")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// offset and termination
+	req, err = http.NewRequest(http.MethodPost, ts.URL+uploadsPath, nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Upload-Length", "100")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("big")))
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	location := ts.URL + resp.Header.Get("Location")
+
+	req, err = http.NewRequest(http.MethodHead, location, nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get("Upload-Offset"))
+	assert.Equal(t, "100", resp.Header.Get("Upload-Length"))
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	req, err = http.NewRequest(http.MethodDelete, location, nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodHead, location, nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", defaultCollection)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// capabilities
+	req, err = http.NewRequest(http.MethodOptions, ts.URL+uploadsPath, nil)
+	require.Nil(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	msg, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	assert.Equal(t, "", string(msg))
+	assert.Equal(t, tusExtensions, resp.Header.Get("Tus-Extension"))
+}
@@ -0,0 +1,519 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidToken is returned when token does not belong to any account
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrAccountNotFound = errors.New("account not found")
+	ErrAccountExists   = errors.New("account with this email already exists")
+	ErrAccountIDTaken  = errors.New("account id already used")
+	// ErrEmailSet is returned when email is set for account, which already has one
+	ErrEmailSet = errors.New("account already has email")
+	// ErrAccountSuspended is returned for tokens of suspended accounts
+	ErrAccountSuspended = errors.New("account suspended")
+	// ErrRecoveryNotFound is returned for unknown and already used recovery codes
+	ErrRecoveryNotFound = errors.New("recovery code not found")
+	ErrRecoveryExpired  = errors.New("recovery code expired")
+)
+
+// Account describes owner of collection
+// collection of account is top-level bucket named by account ID, so token could change without moving data
+type Account struct {
+	ID      string    `json:"id"`
+	Email   string    `json:"email"`
+	Created time.Time `json:"created"`
+	// InvitedBy is account, which invite was used for registration
+	InvitedBy string `json:"invited_by,omitempty"`
+	// Role is RoleAdmin or empty for regular user
+	Role string `json:"role,omitempty"`
+	// Suspended account could not authenticate, its shares are not available
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// accountRecord is stored form of account
+// neither token nor password is stored, only their hashes
+type accountRecord struct {
+	Account
+	TokenHash string `json:"token_hash"`
+	// PasswordHash is empty for accounts, which use only token
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// CreateAccount registers account with given ID and token and creates its collection
+// email should be unique, empty email is allowed for accounts migrated from older versions
+func (store *Store) CreateAccount(id string, email string, token string) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var record *accountRecord
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err = createAccount(tx, id, email, token)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating account")
+	}
+
+	return &record.Account, nil
+}
+
+// createAccount does the work of CreateAccount inside already opened transaction
+func createAccount(tx *bolt.Tx, id string, email string, token string) (*accountRecord, error) {
+	if email != "" {
+		existing, err := accountByEmail(tx, email)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrAccountExists
+		}
+	}
+
+	if _, err := tx.CreateBucket([]byte(id)); err != nil {
+		if err == bolt.ErrBucketExists {
+			return nil, ErrAccountIDTaken
+		}
+		return nil, errors.Wrap(err, "error creating bucket")
+	}
+
+	record := &accountRecord{
+		Account:   Account{ID: id, Email: email, Created: time.Now()},
+		TokenHash: hashToken(token),
+	}
+	return record, saveAccount(tx, record)
+}
+
+// Authenticate returns account, which token belongs to
+// token is either main token of account or one of its API keys, key is nil for main token
+// token is looked up by its hash, so neither token is stored nor compared byte by byte
+func (store *Store) Authenticate(token string) (*Account, *APIKey, error) {
+	if token == "" {
+		return nil, nil, ErrInvalidToken
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	var key *APIKey
+	err = db.View(func(tx *bolt.Tx) error {
+		record, err := accountByToken(tx, token)
+		if err == nil {
+			account = &record.Account
+			return nil
+		}
+		if err != ErrInvalidToken {
+			return err
+		}
+
+		apiKey, err := apiKeyByToken(tx, token)
+		if err != nil {
+			return err
+		}
+		record, err = loadAccount(tx, apiKey.Account)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrInvalidToken
+		}
+		account, key = &record.Account, &apiKey.APIKey
+		return nil
+	})
+	if err == nil && account.Suspended {
+		err = ErrAccountSuspended
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error authenticating")
+	}
+
+	return account, key, nil
+}
+
+// GetAccount returns account by its ID
+func (store *Store) GetAccount(id string) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	err = db.View(func(tx *bolt.Tx) error {
+		record, err := loadAccount(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+		account = &record.Account
+		return nil
+	})
+
+	return account, errors.Wrap(err, "error getting account")
+}
+
+// AccountByEmail returns account registered with email, email is case-insensitive
+func (store *Store) AccountByEmail(email string) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	err = db.View(func(tx *bolt.Tx) error {
+		record, err := accountByEmail(tx, email)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+		account = &record.Account
+		return nil
+	})
+
+	return account, errors.Wrap(err, "error getting account")
+}
+
+// SetEmail sets email of account, which was created without one (like accounts migrated from token-named collections)
+// email could be set only once, so it is not a way to take over address of other account
+func (store *Store) SetEmail(id string, email string) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := loadAccount(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+		if record.Email != "" {
+			return ErrEmailSet
+		}
+		existing, err := accountByEmail(tx, email)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrAccountExists
+		}
+
+		record.Email = email
+		account = &record.Account
+		return saveAccount(tx, record)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error setting email")
+	}
+
+	return account, nil
+}
+
+// RotateToken replaces token of account, old token and all sessions stop working immediately
+// API keys of account are not affected
+func (store *Store) RotateToken(id string, token string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := loadAccount(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+		if err := setToken(tx, record, token); err != nil {
+			return err
+		}
+		return deleteSessions(tx, func(session *Session) bool { return session.Account == id })
+	})
+
+	return errors.Wrap(err, "error rotating token")
+}
+
+// recoveryRecord is stored form of recovery code, code itself is kept only as hash
+type recoveryRecord struct {
+	Account string    `json:"account"`
+	Expires time.Time `json:"expires"`
+}
+
+// CreateRecovery registers one-time recovery code for account with given email
+// returns account, which code was issued for
+func (store *Store) CreateRecovery(email string, code string, expires time.Time) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := accountByEmail(tx, email)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+		account = &record.Account
+
+		recoveries, err := createSystem(tx, recoveriesBucket)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(&recoveryRecord{Account: record.ID, Expires: expires})
+		if err != nil {
+			return errors.Wrap(err, "error encoding recovery")
+		}
+		return errors.Wrap(recoveries.Put([]byte(hashToken(code)), v), "error saving recovery")
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating recovery")
+	}
+
+	return account, nil
+}
+
+// Recover replaces token of account, which recovery code was issued for
+// code could be used only once, other pending codes and sessions of the account are dropped as well
+func (store *Store) Recover(code string, token string) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	err = db.Update(func(tx *bolt.Tx) error {
+		recoveries := system(tx, recoveriesBucket)
+		if recoveries == nil {
+			return ErrRecoveryNotFound
+		}
+		v := recoveries.Get([]byte(hashToken(code)))
+		if v == nil {
+			return ErrRecoveryNotFound
+		}
+		recovery := &recoveryRecord{}
+		if err := json.Unmarshal(v, recovery); err != nil {
+			return errors.Wrap(err, "error decoding recovery")
+		}
+		if time.Now().After(recovery.Expires) {
+			return ErrRecoveryExpired
+		}
+
+		record, err := loadAccount(tx, recovery.Account)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+		if err := setToken(tx, record, token); err != nil {
+			return err
+		}
+		if err := deleteSessions(tx, func(session *Session) bool { return session.Account == record.ID }); err != nil {
+			return err
+		}
+		account = &record.Account
+
+		return deleteRecoveries(recoveries, func(r *recoveryRecord) bool { return r.Account == record.ID })
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error recovering account")
+	}
+
+	return account, nil
+}
+
+// DeleteExpiredRecoveries removes recovery codes, which could not be used anymore
+func (store *Store) DeleteExpiredRecoveries() error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	now := time.Now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		recoveries := system(tx, recoveriesBucket)
+		if recoveries == nil {
+			return nil
+		}
+		return deleteRecoveries(recoveries, func(r *recoveryRecord) bool { return now.After(r.Expires) })
+	})
+
+	return errors.Wrap(err, "error deleting expired recoveries")
+}
+
+// deleteRecoveries removes recovery codes matching fn
+// keys are collected first, because bucket should not be modified while iterating
+func deleteRecoveries(recoveries *bolt.Bucket, fn func(record *recoveryRecord) bool) error {
+	matched := make([][]byte, 0)
+	err := recoveries.ForEach(func(k, v []byte) error {
+		record := &recoveryRecord{}
+		if err := json.Unmarshal(v, record); err != nil {
+			return errors.Wrap(err, "error decoding recovery")
+		}
+		if fn(record) {
+			matched = append(matched, copyValue(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range matched {
+		if err := recoveries.Delete(k); err != nil {
+			return errors.Wrap(err, "error deleting recovery")
+		}
+	}
+	return nil
+}
+
+// setToken replaces token hash of account and its index entry
+func setToken(tx *bolt.Tx, record *accountRecord, token string) error {
+	if tokens := system(tx, tokensBucket); tokens != nil {
+		if err := tokens.Delete([]byte(record.TokenHash)); err != nil {
+			return errors.Wrap(err, "error deleting token index")
+		}
+	}
+
+	record.TokenHash = hashToken(token)
+	return saveAccount(tx, record)
+}
+
+// accountByToken returns account, which token belongs to
+func accountByToken(tx *bolt.Tx, token string) (*accountRecord, error) {
+	hash := hashToken(token)
+
+	tokens := system(tx, tokensBucket)
+	if tokens == nil {
+		return nil, ErrInvalidToken
+	}
+	id := tokens.Get([]byte(hash))
+	if id == nil {
+		return nil, ErrInvalidToken
+	}
+
+	record, err := loadAccount(tx, string(id))
+	if err != nil {
+		return nil, err
+	}
+	// index could point to account, which token was changed
+	if record == nil || subtle.ConstantTimeCompare([]byte(record.TokenHash), []byte(hash)) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	return record, nil
+}
+
+// accountByEmail returns account registered with email, nil is returned if there is no such account
+func accountByEmail(tx *bolt.Tx, email string) (*accountRecord, error) {
+	emails := system(tx, emailsBucket)
+	if emails == nil {
+		return nil, nil
+	}
+
+	id := emails.Get([]byte(strings.ToLower(email)))
+	if id == nil {
+		return nil, nil
+	}
+
+	return loadAccount(tx, string(id))
+}
+
+// loadAccount returns decoded account, nil is returned for unknown ID
+func loadAccount(tx *bolt.Tx, id string) (*accountRecord, error) {
+	accounts := system(tx, accountsBucket)
+	if accounts == nil {
+		return nil, nil
+	}
+
+	v := accounts.Get([]byte(id))
+	if v == nil {
+		return nil, nil
+	}
+
+	record := &accountRecord{}
+	err := json.Unmarshal(v, record)
+	return record, errors.Wrap(err, "error decoding account")
+}
+
+// saveAccount stores account together with token and email indexes
+func saveAccount(tx *bolt.Tx, record *accountRecord) error {
+	accounts, err := createSystem(tx, accountsBucket)
+	if err != nil {
+		return err
+	}
+	tokens, err := createSystem(tx, tokensBucket)
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "error encoding account")
+	}
+	if err := accounts.Put([]byte(record.ID), v); err != nil {
+		return errors.Wrap(err, "error saving account")
+	}
+	if err := tokens.Put([]byte(record.TokenHash), []byte(record.ID)); err != nil {
+		return errors.Wrap(err, "error saving token index")
+	}
+
+	if record.Email == "" {
+		return nil
+	}
+	emails, err := createSystem(tx, emailsBucket)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(emails.Put([]byte(strings.ToLower(record.Email)), []byte(record.ID)), "error saving email index")
+}
+
+// hashToken returns hex encoded SHA-256 of token
+// tokens are long random strings, so salt is not needed and hash could be used as index key
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomID generates random ID for records created by store itself
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
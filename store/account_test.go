@@ -0,0 +1,160 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccount(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	account, err := s.CreateAccount("id", "Neo@example.com", "secret token")
+	require.Nil(t, err)
+	assert.Equal(t, "id", account.ID)
+	assert.False(t, account.Created.IsZero())
+
+	// collection is named by account ID
+	require.Nil(t, s.Put("id", []string{"file"}, strings.NewReader("data")))
+
+	tt := []struct {
+		Token string
+		ID    string
+		Error error
+	}{
+		{"secret token", "id", nil},
+		{"id", "", ErrInvalidToken},
+		{"public", "", ErrInvalidToken},
+		{"", "", ErrInvalidToken},
+	}
+
+	for _, test := range tt {
+		account, _, err := s.Authenticate(test.Token)
+		if test.Error != nil {
+			assert.Equal(t, test.Error, errors.Cause(err), test.Token)
+			continue
+		}
+		require.Nil(t, err)
+		assert.Equal(t, test.ID, account.ID)
+		assert.Equal(t, "Neo@example.com", account.Email)
+	}
+
+	// emails and IDs are unique
+	_, err = s.CreateAccount("other", "neo@example.com", "other token")
+	assert.Equal(t, ErrAccountExists, errors.Cause(err))
+	_, err = s.CreateAccount("id", "trinity@example.com", "other token")
+	assert.Equal(t, ErrAccountIDTaken, errors.Cause(err))
+
+	account, err = s.GetAccount("id")
+	require.Nil(t, err)
+	assert.Equal(t, "Neo@example.com", account.Email)
+	_, err = s.GetAccount("missing")
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(err))
+
+	// token itself is not stored
+	db, err := s.open()
+	require.Nil(t, err)
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		return system(tx, accountsBucket).ForEach(func(k, v []byte) error {
+			assert.NotContains(t, string(v), "secret token")
+			return nil
+		})
+	})
+	require.Nil(t, err)
+}
+
+func TestSetEmail(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	_, err = s.CreateAccount("neo", "neo@zion.org", "neo token")
+	require.Nil(t, err)
+	_, err = s.CreateAccount("migrated", "", "old token")
+	require.Nil(t, err)
+
+	_, err = s.SetEmail("migrated", "NEO@zion.org")
+	assert.Equal(t, ErrAccountExists, errors.Cause(err))
+	_, err = s.SetEmail("neo", "other@zion.org")
+	assert.Equal(t, ErrEmailSet, errors.Cause(err))
+	_, err = s.SetEmail("missing", "other@zion.org")
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(err))
+
+	account, err := s.SetEmail("migrated", "trinity@zion.org")
+	require.Nil(t, err)
+	assert.Equal(t, "trinity@zion.org", account.Email)
+
+	// email is indexed, so recovery and password login work
+	account, err = s.AccountByEmail("Trinity@zion.org")
+	require.Nil(t, err)
+	assert.Equal(t, "migrated", account.ID)
+	require.Nil(t, s.SetPassword("migrated", "there is no spoon"))
+
+	_, err = s.SetEmail("migrated", "other@zion.org")
+	assert.Equal(t, ErrEmailSet, errors.Cause(err))
+}
+
+func TestRecovery(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	_, err = s.CreateAccount("id", "neo@example.com", "leaked")
+	require.Nil(t, err)
+	require.Nil(t, s.Put("id", []string{"file"}, strings.NewReader("data")))
+
+	// rotation
+	require.Nil(t, s.RotateToken("id", "rotated"))
+	_, _, err = s.Authenticate("leaked")
+	assert.Equal(t, ErrInvalidToken, errors.Cause(err))
+	account, _, err := s.Authenticate("rotated")
+	require.Nil(t, err)
+	assert.Equal(t, "id", account.ID)
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(s.RotateToken("missing", "token")))
+
+	// recovery
+	_, err = s.CreateRecovery("trinity@example.com", "code", time.Now().Add(time.Hour))
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(err))
+	account, err = s.CreateRecovery("NEO@example.com", "code", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, "neo@example.com", account.Email)
+	_, err = s.CreateRecovery("neo@example.com", "second", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	_, err = s.CreateRecovery("neo@example.com", "old", time.Now().Add(-time.Minute))
+	require.Nil(t, err)
+
+	_, err = s.Recover("old", "new")
+	assert.Equal(t, ErrRecoveryExpired, errors.Cause(err))
+	account, err = s.Recover("code", "recovered")
+	require.Nil(t, err)
+	assert.Equal(t, "id", account.ID)
+
+	// codes are used once, other pending codes are dropped
+	_, err = s.Recover("code", "again")
+	assert.Equal(t, ErrRecoveryNotFound, errors.Cause(err))
+	_, err = s.Recover("second", "again")
+	assert.Equal(t, ErrRecoveryNotFound, errors.Cause(err))
+
+	// collection is kept
+	_, _, err = s.Authenticate("rotated")
+	assert.Equal(t, ErrInvalidToken, errors.Cause(err))
+	account, _, err = s.Authenticate("recovered")
+	require.Nil(t, err)
+	result, err := s.Get(account.ID, []string{"file"})
+	require.Nil(t, err)
+	assert.Equal(t, "data", string(result))
+
+	_, err = s.CreateRecovery("neo@example.com", "expired", time.Now().Add(-time.Minute))
+	require.Nil(t, err)
+	require.Nil(t, s.DeleteExpiredRecoveries())
+	_, err = s.Recover("expired", "new")
+	assert.Equal(t, ErrRecoveryNotFound, errors.Cause(err))
+}
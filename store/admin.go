@@ -0,0 +1,367 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// RoleAdmin allows to manage accounts and server settings, empty role is regular user
+const RoleAdmin = "admin"
+
+var ErrInvalidRole = errors.New("invalid role")
+
+// Usage describes space taken by collection
+type Usage struct {
+	Files   int   `json:"files"`
+	Folders int   `json:"folders"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// AccountInfo describes account together with resources it uses
+type AccountInfo struct {
+	Account
+	Usage  Usage `json:"usage"`
+	Shares int   `json:"shares"`
+}
+
+// ServerStats describes database as a whole
+type ServerStats struct {
+	Accounts int `json:"accounts"`
+	Shares   int `json:"shares"`
+	Uploads  int `json:"uploads"`
+	// Size is size of database in bytes
+	Size int64 `json:"size"`
+	// DB is statistics of bolt database, database is opened for every operation,
+	// so transaction counters cover only the request itself, while page counters describe the file
+	DB bolt.Stats `json:"db"`
+}
+
+// ListAccounts returns all accounts with their usage, oldest accounts go first
+func (store *Store) ListAccounts() ([]*AccountInfo, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	infos := make([]*AccountInfo, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		shares := make(map[string]int)
+		err := forEachShare(tx, func(record *shareRecord) error {
+			shares[record.Owner] += 1
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		accounts := system(tx, accountsBucket)
+		if accounts == nil {
+			return nil
+		}
+		return accounts.ForEach(func(k, v []byte) error {
+			record := &accountRecord{}
+			if err := json.Unmarshal(v, record); err != nil {
+				return errors.Wrap(err, "error decoding account")
+			}
+
+			info := &AccountInfo{Account: record.Account, Shares: shares[record.ID]}
+			if b := tx.Bucket([]byte(record.ID)); b != nil {
+				collectionUsage(b, &info.Usage)
+			}
+			infos = append(infos, info)
+			return nil
+		})
+	})
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Created.Before(infos[j].Created) })
+	return infos, errors.Wrap(err, "error listing accounts")
+}
+
+// SetSuspended suspends or restores account
+// suspended account could not authenticate neither with main token nor with API keys, its shares are not available
+func (store *Store) SetSuspended(id string, suspended bool) (*Account, error) {
+	return store.updateAccount(id, func(record *accountRecord) {
+		record.Suspended = suspended
+	})
+}
+
+// SetRole changes role of account, role should be RoleAdmin or empty
+func (store *Store) SetRole(id string, role string) (*Account, error) {
+	if role != "" && role != RoleAdmin {
+		return nil, errors.Wrapf(ErrInvalidRole, "unknown role \"%s\"", role)
+	}
+
+	return store.updateAccount(id, func(record *accountRecord) {
+		record.Role = role
+	})
+}
+
+// DeleteAccount removes account with its collection, shares, aliases, mounts, uploads, API keys, recovery codes and sessions
+// invites of account are revoked, but kept, so it is still known who invited whom
+func (store *Store) DeleteAccount(id string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := loadAccount(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+
+		// records are collected first, because buckets should not be modified while iterating
+		tokens := make([]string, 0)
+		err = forEachShare(tx, func(share *shareRecord) error {
+			if share.Owner == id {
+				tokens = append(tokens, share.Token)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			if err := deleteShare(tx, token); err != nil {
+				return err
+			}
+		}
+
+		if err := deleteAccountAliases(tx, id); err != nil {
+			return err
+		}
+		if err := deleteMounts(tx, id); err != nil {
+			return err
+		}
+		if err := deleteAccountUploads(tx, id); err != nil {
+			return err
+		}
+		if err := deleteAccountKeys(tx, id); err != nil {
+			return err
+		}
+		if recoveries := system(tx, recoveriesBucket); recoveries != nil {
+			err := deleteRecoveries(recoveries, func(r *recoveryRecord) bool { return r.Account == id })
+			if err != nil {
+				return err
+			}
+		}
+		if err := revokeAccountInvites(tx, id); err != nil {
+			return err
+		}
+		if err := deleteSessions(tx, func(session *Session) bool { return session.Account == id }); err != nil {
+			return err
+		}
+
+		if err := system(tx, tokensBucket).Delete([]byte(record.TokenHash)); err != nil {
+			return errors.Wrap(err, "error deleting token index")
+		}
+		if record.Email != "" {
+			if err := system(tx, emailsBucket).Delete([]byte(strings.ToLower(record.Email))); err != nil {
+				return errors.Wrap(err, "error deleting email index")
+			}
+		}
+		if err := system(tx, accountsBucket).Delete([]byte(id)); err != nil {
+			return errors.Wrap(err, "error deleting account")
+		}
+
+		if tx.Bucket([]byte(id)) == nil {
+			return nil
+		}
+		return errors.Wrap(tx.DeleteBucket([]byte(id)), "error deleting collection")
+	})
+
+	return errors.Wrap(err, "error deleting account")
+}
+
+// Stats returns statistics of the database
+func (store *Store) Stats() (*ServerStats, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	stats := &ServerStats{}
+	err = db.View(func(tx *bolt.Tx) error {
+		stats.Size = tx.Size()
+		if accounts := system(tx, accountsBucket); accounts != nil {
+			stats.Accounts = accounts.Stats().KeyN
+		}
+		if shares := system(tx, sharesBucket); shares != nil {
+			stats.Shares = shares.Stats().KeyN
+		}
+		if uploads := system(tx, uploadsBucket); uploads != nil {
+			return uploads.ForEach(func(k, v []byte) error {
+				if v == nil {
+					stats.Uploads += 1
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading stats")
+	}
+
+	stats.DB = db.Stats()
+	return stats, nil
+}
+
+// updateAccount applies fn to account and saves it
+func (store *Store) updateAccount(id string, fn func(record *accountRecord)) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := loadAccount(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+		fn(record)
+		account = &record.Account
+		return saveAccount(tx, record)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error updating account")
+	}
+
+	return account, nil
+}
+
+// collectionUsage adds files, folders and bytes of bucket to usage
+func collectionUsage(b *bolt.Bucket, usage *Usage) {
+	b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			usage.Folders += 1
+			collectionUsage(b.Bucket(k), usage)
+			return nil
+		}
+		usage.Files += 1
+		usage.Bytes += int64(len(v))
+		return nil
+	})
+}
+
+// deleteAccountAliases removes aliases created by account
+func deleteAccountAliases(tx *bolt.Tx, id string) error {
+	aliases := system(tx, aliasesBucket)
+	if aliases == nil {
+		return nil
+	}
+
+	owned := make([][]byte, 0)
+	err := aliases.ForEach(func(k, v []byte) error {
+		info := &AliasInfo{}
+		if err := json.Unmarshal(v, info); err != nil {
+			return errors.Wrap(err, "error decoding alias")
+		}
+		if info.Owner == id {
+			owned = append(owned, copyValue(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range owned {
+		if err := aliases.Delete(k); err != nil {
+			return errors.Wrap(err, "error deleting alias")
+		}
+	}
+	return nil
+}
+
+// deleteAccountUploads removes staged uploads of account collection
+func deleteAccountUploads(tx *bolt.Tx, id string) error {
+	uploads := system(tx, uploadsBucket)
+	if uploads == nil {
+		return nil
+	}
+
+	owned := make([][]byte, 0)
+	err := uploads.ForEach(func(k, v []byte) error {
+		b := uploads.Bucket(k)
+		if b == nil {
+			return nil
+		}
+		upload, err := uploadInfo(b)
+		if err != nil {
+			return err
+		}
+		if upload.Collection == id {
+			owned = append(owned, copyValue(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range owned {
+		if err := uploads.DeleteBucket(k); err != nil {
+			return errors.Wrap(err, "error deleting upload")
+		}
+	}
+	return nil
+}
+
+// deleteAccountKeys removes API keys of account
+func deleteAccountKeys(tx *bolt.Tx, id string) error {
+	owned := make([][]byte, 0)
+	err := forEachAPIKey(tx, func(hash []byte, record *apiKeyRecord) error {
+		if record.Account == id {
+			owned = append(owned, hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range owned {
+		if err := system(tx, apiKeysBucket).Delete(hash); err != nil {
+			return errors.Wrap(err, "error deleting api key")
+		}
+	}
+	return nil
+}
+
+// revokeAccountInvites revokes invites created by account
+func revokeAccountInvites(tx *bolt.Tx, id string) error {
+	owned := make([]*Invite, 0)
+	err := forEachInvite(tx, func(invite *Invite) error {
+		if invite.Owner == id && !invite.Revoked {
+			owned = append(owned, invite)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, invite := range owned {
+		invite.Revoked = true
+		if err := saveInvite(tx, invite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	_, err = s.CreateAccount("neo", "neo@zion.org", "neo token")
+	require.Nil(t, err)
+	_, err = s.CreateAccount("smith", "smith@matrix.com", "smith token")
+	require.Nil(t, err)
+
+	require.Nil(t, s.Put("smith", []string{"plans", "zion.txt"}, bytes.NewReader([]byte("destroy"))))
+	require.Nil(t, s.Put("smith", []string{"me.txt"}, bytes.NewReader([]byte("me"))))
+	require.Nil(t, s.Share("smith", []string{"plans"}, "plans", ShareOptions{}))
+	require.Nil(t, s.Share("smith", []string{"me.txt"}, "me", ShareOptions{Mode: ShareLive}))
+	_, err = s.SetAlias("smith", "plans", "plans")
+	require.Nil(t, err)
+	_, err = s.CreateAPIKey("smith", "smith key", APIKey{ID: "key", Scopes: []string{ScopeRead}})
+	require.Nil(t, err)
+	_, err = s.CreateUpload("smith", []string{"big.bin"}, "upload", 10, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	_, err = s.CreateInvite("smith", "invite", 1, time.Time{}, -1)
+	require.Nil(t, err)
+	require.Nil(t, s.Share("neo", []string{}, "neo share", ShareOptions{Mode: ShareLive}))
+	_, err = s.Mount("neo", []string{"from smith"}, "plans", "")
+	require.Nil(t, err)
+
+	accounts, err := s.ListAccounts()
+	require.Nil(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "neo", accounts[0].ID)
+	assert.Equal(t, "smith", accounts[1].ID)
+	assert.Equal(t, Usage{Files: 2, Folders: 1, Bytes: 9}, accounts[1].Usage)
+	assert.Equal(t, 2, accounts[1].Shares)
+
+	account, err := s.SetRole("neo", RoleAdmin)
+	require.Nil(t, err)
+	assert.Equal(t, RoleAdmin, account.Role)
+	_, err = s.SetRole("neo", "god")
+	assert.Equal(t, ErrInvalidRole, errors.Cause(err))
+	_, err = s.SetRole("missing", RoleAdmin)
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(err))
+
+	// suspended account could not authenticate and its shares are hidden
+	_, err = s.SetSuspended("smith", true)
+	require.Nil(t, err)
+	_, _, err = s.Authenticate("smith token")
+	assert.Equal(t, ErrAccountSuspended, errors.Cause(err))
+	_, _, err = s.Authenticate("smith key")
+	assert.Equal(t, ErrAccountSuspended, errors.Cause(err))
+	_, err = s.GetShared("me", "", []string{})
+	assert.Equal(t, ErrShareNotFound, errors.Cause(err))
+
+	_, err = s.SetSuspended("smith", false)
+	require.Nil(t, err)
+	_, _, err = s.Authenticate("smith token")
+	require.Nil(t, err)
+	data, err := s.GetShared("me", "", []string{})
+	require.Nil(t, err)
+	assert.Equal(t, "me", string(data))
+
+	require.Nil(t, s.DeleteAccount("smith"))
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(s.DeleteAccount("smith")))
+
+	_, _, err = s.Authenticate("smith token")
+	assert.Equal(t, ErrInvalidToken, errors.Cause(err))
+	_, _, err = s.Authenticate("smith key")
+	assert.Equal(t, ErrInvalidToken, errors.Cause(err))
+	_, err = s.GetShared("plans", "", []string{})
+	assert.Equal(t, ErrShareNotFound, errors.Cause(err))
+	_, err = s.ResolveAlias("plans")
+	assert.Equal(t, ErrAliasNotFound, errors.Cause(err))
+	assert.Equal(t, ErrInviteNotFound, errors.Cause(s.CheckInvite("invite")))
+	_, err = s.GetUpload("smith", "upload")
+	assert.Equal(t, ErrUploadNotFound, errors.Cause(err))
+	_, err = s.Get("smith", []string{})
+	assert.NotNil(t, err)
+
+	// email could be registered again
+	_, err = s.CreateAccount("smith2", "smith@matrix.com", "smith token")
+	require.Nil(t, err)
+
+	stats, err := s.Stats()
+	require.Nil(t, err)
+	assert.Equal(t, 2, stats.Accounts)
+	assert.Equal(t, 1, stats.Shares)
+	assert.Equal(t, 0, stats.Uploads)
+	assert.True(t, stats.Size > 0)
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrAliasNotFound is returned for unknown aliases and for aliases of other owners
+	ErrAliasNotFound = errors.New("alias not found")
+	ErrAliasTaken    = errors.New("alias already taken")
+	ErrInvalidAlias  = errors.New("invalid alias")
+)
+
+// aliasPattern allows latin letters, digits, "-" and "_", alias should start with letter or digit
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{1,62}$`)
+
+// AliasInfo describes human-friendly name of share
+type AliasInfo struct {
+	// Alias is kept as it was given, but lookup is case-insensitive
+	Alias   string    `json:"alias"`
+	Token   string    `json:"token"`
+	Owner   string    `json:"owner"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// SetAlias points alias to share of owner
+// alias is created when it does not exist yet, alias of the same owner is re-pointed to given share
+func (store *Store) SetAlias(owner string, alias string, token string) (*AliasInfo, error) {
+	if !aliasPattern.MatchString(alias) {
+		return nil, errors.Wrapf(ErrInvalidAlias, "alias \"%s\" should consist of 2-63 latin letters, digits, \"-\" and \"_\"", alias)
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var info *AliasInfo
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := ownShare(tx, owner, token); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		info, err = loadAlias(tx, alias)
+		if err != nil {
+			return err
+		}
+		if info != nil && info.Owner != owner {
+			return ErrAliasTaken
+		}
+		if info == nil {
+			info = &AliasInfo{Owner: owner, Created: now}
+		}
+		info.Alias = alias
+		info.Token = token
+		info.Updated = now
+
+		return saveAlias(tx, info)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error setting alias")
+	}
+
+	return info, nil
+}
+
+// ResolveAlias returns token of share, which alias points to
+func (store *Store) ResolveAlias(alias string) (string, error) {
+	db, err := store.open()
+	if err != nil {
+		return "", errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	token := ""
+	err = db.View(func(tx *bolt.Tx) error {
+		info, err := loadAlias(tx, alias)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			return ErrAliasNotFound
+		}
+		token = info.Token
+		return nil
+	})
+
+	return token, errors.Wrap(err, "error resolving alias")
+}
+
+// ListAliases returns all aliases of owner
+func (store *Store) ListAliases(owner string) ([]*AliasInfo, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	aliases := make([]*AliasInfo, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		b := system(tx, aliasesBucket)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			info := &AliasInfo{}
+			if err := json.Unmarshal(v, info); err != nil {
+				return errors.Wrap(err, "error decoding alias")
+			}
+			if info.Owner == owner {
+				aliases = append(aliases, info)
+			}
+			return nil
+		})
+	})
+
+	return aliases, errors.Wrap(err, "error listing aliases")
+}
+
+// DeleteAlias removes alias of owner, share itself is not touched
+func (store *Store) DeleteAlias(owner string, alias string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		info, err := loadAlias(tx, alias)
+		if err != nil {
+			return err
+		}
+		if info == nil || info.Owner != owner {
+			return ErrAliasNotFound
+		}
+
+		return errors.Wrap(system(tx, aliasesBucket).Delete(aliasKey(alias)), "error deleting alias")
+	})
+
+	return errors.Wrap(err, "error deleting alias")
+}
+
+// loadAlias returns decoded alias, nil is returned for unknown alias
+func loadAlias(tx *bolt.Tx, alias string) (*AliasInfo, error) {
+	b := system(tx, aliasesBucket)
+	if b == nil {
+		return nil, nil
+	}
+
+	v := b.Get(aliasKey(alias))
+	if v == nil {
+		return nil, nil
+	}
+
+	info := &AliasInfo{}
+	err := json.Unmarshal(v, info)
+	return info, errors.Wrap(err, "error decoding alias")
+}
+
+func saveAlias(tx *bolt.Tx, info *AliasInfo) error {
+	b, err := createSystem(tx, aliasesBucket)
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, "error encoding alias")
+	}
+
+	return errors.Wrap(b.Put(aliasKey(info.Alias), v), "error saving alias")
+}
+
+// aliasKey makes lookup case-insensitive
+func aliasKey(alias string) []byte {
+	return []byte(strings.ToLower(alias))
+}
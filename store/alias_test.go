@@ -0,0 +1,65 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlias(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Create("other"))
+	require.Nil(t, s.Share("public", []string{"a"}, "first", ShareOptions{}))
+	require.Nil(t, s.Share("public", []string{"1"}, "second", ShareOptions{}))
+	require.Nil(t, s.Share("other", []string{}, "foreign", ShareOptions{}))
+
+	tt := []struct {
+		Owner string
+		Alias string
+		Token string
+		Error error
+	}{
+		{"public", "Q3-Report", "first", nil},
+		{"public", "x", "first", ErrInvalidAlias},
+		{"public", "-report", "first", ErrInvalidAlias},
+		{"public", "report/2", "first", ErrInvalidAlias},
+		{"public", "report", "foreign", ErrShareNotFound},
+		{"other", "q3-report", "foreign", ErrAliasTaken},
+		{"other", "q4_report", "foreign", nil},
+	}
+
+	for _, test := range tt {
+		_, err := s.SetAlias(test.Owner, test.Alias, test.Token)
+		assert.Equal(t, test.Error, errors.Cause(err), test.Alias)
+	}
+
+	// lookup is case-insensitive
+	token, err := s.ResolveAlias("q3-REPORT")
+	require.Nil(t, err)
+	assert.Equal(t, "first", token)
+
+	// alias is re-pointed by the same owner
+	alias, err := s.SetAlias("public", "q3-report", "second")
+	require.Nil(t, err)
+	assert.Equal(t, "q3-report", alias.Alias)
+	token, err = s.ResolveAlias("Q3-Report")
+	require.Nil(t, err)
+	assert.Equal(t, "second", token)
+
+	aliases, err := s.ListAliases("public")
+	require.Nil(t, err)
+	require.Len(t, aliases, 1)
+	assert.Equal(t, "second", aliases[0].Token)
+	assert.True(t, aliases[0].Updated.After(aliases[0].Created))
+
+	// only owner could revoke alias
+	assert.Equal(t, ErrAliasNotFound, errors.Cause(s.DeleteAlias("other", "q3-report")))
+	require.Nil(t, s.DeleteAlias("public", "Q3-REPORT"))
+	_, err = s.ResolveAlias("q3-report")
+	assert.Equal(t, ErrAliasNotFound, errors.Cause(err))
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// scopes of API keys
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+	ScopeShare  = "share"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrTokenExpired   = errors.New("token expired")
+	ErrInvalidScope   = errors.New("invalid scope")
+)
+
+// APIKey is additional token of account with limited access
+// key token is shown only once on creation, only its hash is stored
+type APIKey struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	// Scopes lists allowed operations, see Scope* constants
+	Scopes []string `json:"scopes"`
+	// Prefix restricts key to subtree of collection, empty prefix means whole collection
+	Prefix []string `json:"prefix"`
+	// Expires is zero for keys, which never expire
+	Expires time.Time `json:"expires,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// apiKeyRecord is stored form of API key
+type apiKeyRecord struct {
+	APIKey
+	Account string `json:"account"`
+}
+
+// HasScope checks if key allows given operation
+func (key *APIKey) HasScope(scope string) bool {
+	for _, s := range key.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers checks if path is inside prefix of the key
+func (key *APIKey) Covers(keys []string) bool {
+	return hasPrefix(keys, key.Prefix)
+}
+
+// CreateAPIKey adds API key with given token to account
+// ID and token are provided by caller, like tokens of shares
+func (store *Store) CreateAPIKey(account string, token string, key APIKey) (*APIKey, error) {
+	if len(key.Scopes) == 0 {
+		return nil, errors.Wrap(ErrInvalidScope, "at least one scope should be given")
+	}
+	for _, scope := range key.Scopes {
+		switch scope {
+		case ScopeRead, ScopeWrite, ScopeDelete, ScopeShare:
+		default:
+			return nil, errors.Wrapf(ErrInvalidScope, "unknown scope \"%s\"", scope)
+		}
+	}
+	if key.Prefix == nil {
+		key.Prefix = []string{}
+	}
+	if err := store.ValidatePath(key.Prefix); err != nil {
+		return nil, err
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	key.Created = time.Now()
+	record := &apiKeyRecord{APIKey: key, Account: account}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		owner, err := loadAccount(tx, account)
+		if err != nil {
+			return err
+		}
+		if owner == nil {
+			return ErrAccountNotFound
+		}
+
+		keys, err := createSystem(tx, apiKeysBucket)
+		if err != nil {
+			return err
+		}
+		hash := []byte(hashToken(token))
+		if keys.Get(hash) != nil {
+			return errors.New("api key token already used")
+		}
+
+		v, err := json.Marshal(record)
+		if err != nil {
+			return errors.Wrap(err, "error encoding api key")
+		}
+		return errors.Wrap(keys.Put(hash, v), "error saving api key")
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating api key")
+	}
+
+	return &record.APIKey, nil
+}
+
+// ListAPIKeys returns API keys of account, oldest first
+func (store *Store) ListAPIKeys(account string) ([]*APIKey, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	result := make([]*APIKey, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		return forEachAPIKey(tx, func(hash []byte, record *apiKeyRecord) error {
+			if record.Account == account {
+				result = append(result, &record.APIKey)
+			}
+			return nil
+		})
+	})
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Created.Before(result[j].Created) })
+	return result, errors.Wrap(err, "error listing api keys")
+}
+
+// RevokeAPIKey deletes API key of account by its ID
+func (store *Store) RevokeAPIKey(account string, id string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		var found []byte
+		err := forEachAPIKey(tx, func(hash []byte, record *apiKeyRecord) error {
+			if record.Account == account && record.ID == id {
+				found = hash
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if found == nil {
+			return ErrAPIKeyNotFound
+		}
+
+		return errors.Wrap(system(tx, apiKeysBucket).Delete(found), "error deleting api key")
+	})
+
+	return errors.Wrap(err, "error revoking api key")
+}
+
+// apiKeyByToken returns API key, which token belongs to
+func apiKeyByToken(tx *bolt.Tx, token string) (*apiKeyRecord, error) {
+	keys := system(tx, apiKeysBucket)
+	if keys == nil {
+		return nil, ErrInvalidToken
+	}
+
+	v := keys.Get([]byte(hashToken(token)))
+	if v == nil {
+		return nil, ErrInvalidToken
+	}
+
+	record := &apiKeyRecord{}
+	if err := json.Unmarshal(v, record); err != nil {
+		return nil, errors.Wrap(err, "error decoding api key")
+	}
+	if !record.Expires.IsZero() && time.Now().After(record.Expires) {
+		return nil, ErrTokenExpired
+	}
+
+	return record, nil
+}
+
+// forEachAPIKey calls fn for every API key with hash of its token
+// hash is copied, so it stays valid after iteration
+func forEachAPIKey(tx *bolt.Tx, fn func(hash []byte, record *apiKeyRecord) error) error {
+	keys := system(tx, apiKeysBucket)
+	if keys == nil {
+		return nil
+	}
+
+	return keys.ForEach(func(k, v []byte) error {
+		record := &apiKeyRecord{}
+		if err := json.Unmarshal(v, record); err != nil {
+			return errors.Wrap(err, "error decoding api key")
+		}
+		return fn(copyValue(k), record)
+	})
+}
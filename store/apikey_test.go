@@ -0,0 +1,81 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	_, err = s.CreateAccount("id", "neo@example.com", "main")
+	require.Nil(t, err)
+	_, err = s.CreateAccount("other", "trinity@example.com", "other main")
+	require.Nil(t, err)
+
+	key, err := s.CreateAPIKey("id", "ci token", APIKey{ID: "ci", Label: "CI", Scopes: []string{ScopeRead}, Prefix: []string{"builds"}})
+	require.Nil(t, err)
+	assert.False(t, key.Created.IsZero())
+	_, err = s.CreateAPIKey("id", "old token", APIKey{ID: "old", Scopes: []string{ScopeWrite}, Expires: time.Now().Add(-time.Minute)})
+	require.Nil(t, err)
+
+	_, err = s.CreateAPIKey("id", "bad token", APIKey{ID: "bad", Scopes: []string{"admin"}})
+	assert.Equal(t, ErrInvalidScope, errors.Cause(err))
+	_, err = s.CreateAPIKey("id", "bad token", APIKey{ID: "bad"})
+	assert.Equal(t, ErrInvalidScope, errors.Cause(err))
+	_, err = s.CreateAPIKey("missing", "bad token", APIKey{ID: "bad", Scopes: []string{ScopeRead}})
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(err))
+
+	tt := []struct {
+		Token string
+		Key   string
+		Error error
+	}{
+		{"main", "", nil},
+		{"ci token", "ci", nil},
+		{"old token", "", ErrTokenExpired},
+		{"ci", "", ErrInvalidToken},
+	}
+
+	for _, test := range tt {
+		account, key, err := s.Authenticate(test.Token)
+		if test.Error != nil {
+			assert.Equal(t, test.Error, errors.Cause(err), test.Token)
+			continue
+		}
+		require.Nil(t, err)
+		assert.Equal(t, "id", account.ID)
+		if test.Key == "" {
+			assert.Nil(t, key)
+			continue
+		}
+		require.NotNil(t, key)
+		assert.Equal(t, test.Key, key.ID)
+	}
+
+	_, key, err = s.Authenticate("ci token")
+	require.Nil(t, err)
+	assert.True(t, key.HasScope(ScopeRead))
+	assert.False(t, key.HasScope(ScopeWrite))
+	assert.True(t, key.Covers([]string{"builds", "42"}))
+	assert.False(t, key.Covers([]string{"secrets"}))
+	assert.False(t, key.Covers([]string{}))
+
+	keys, err := s.ListAPIKeys("id")
+	require.Nil(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "ci", keys[0].ID)
+	assert.Equal(t, "old", keys[1].ID)
+
+	// keys of other accounts could not be revoked
+	assert.Equal(t, ErrAPIKeyNotFound, errors.Cause(s.RevokeAPIKey("other", "ci")))
+	require.Nil(t, s.RevokeAPIKey("id", "ci"))
+	_, _, err = s.Authenticate("ci token")
+	assert.Equal(t, ErrInvalidToken, errors.Cause(err))
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrDropTooLarge = errors.New("file exceeds drop box size limit")
+	ErrDropBoxFull  = errors.New("drop box upload limit reached")
+)
+
+// DropUpload describes file uploaded to drop box share
+type DropUpload struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Uploaded time.Time `json:"uploaded"`
+	IP       string    `json:"ip"`
+}
+
+// DropFile writes file into folder shared as drop box
+// name collisions are resolved by adding number to the name ("report (1).pdf")
+// upload is recorded in share info, name under which file was stored is returned
+func (store *Store) DropFile(token string, password string, name string, ip string, file io.Reader) (string, error) {
+	if err := store.ValidatePath([]string{name}); err != nil {
+		return "", err
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return "", errors.Wrap(err, "error opening database")
+	}
+
+	// size limit is needed before reading the file
+	var record *shareRecord
+	err = db.View(func(tx *bolt.Tx) error {
+		record, err = dropBox(tx, token, password)
+		return err
+	})
+	// database is not kept open while file is read
+	db.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "error dropping file")
+	}
+
+	var data []byte
+	if record.MaxSize > 0 {
+		data, err = ioutil.ReadAll(io.LimitReader(file, record.MaxSize+1))
+	} else {
+		data, err = ioutil.ReadAll(file)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "error reading file from reader")
+	}
+	if record.MaxSize > 0 && int64(len(data)) > record.MaxSize {
+		return "", errors.Wrap(ErrDropTooLarge, "error dropping file")
+	}
+
+	db, err = store.open()
+	if err != nil {
+		return "", errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// share could be changed while file was read
+		record, err := dropBox(tx, token, password)
+		if err != nil {
+			return err
+		}
+
+		folder, err := sharedFolder(tx, record)
+		if err != nil {
+			return err
+		}
+
+		name = uniqueName(folder, name)
+		if err := folder.Put([]byte(name), data); err != nil {
+			return errors.Wrap(err, "error writing file")
+		}
+
+		record.Uploads = append(record.Uploads, DropUpload{
+			Name:     name,
+			Size:     int64(len(data)),
+			Uploaded: time.Now(),
+			IP:       ip,
+		})
+		return saveShare(tx, record)
+	})
+
+	return name, errors.Wrap(err, "error dropping file")
+}
+
+// dropBox returns record of drop box share, if it accepts uploads
+func dropBox(tx *bolt.Tx, token string, password string) (*shareRecord, error) {
+	record, err := activeShare(tx, token)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.Mode != ShareDropBox {
+		return nil, ErrShareNotFound
+	}
+	if err := record.check(password, time.Now()); err != nil {
+		return nil, err
+	}
+	if record.MaxUploads > 0 && len(record.Uploads) >= record.MaxUploads {
+		return nil, ErrDropBoxFull
+	}
+
+	return record, nil
+}
+
+// uniqueName returns name, which is not used inside bucket
+// number is added before extension: "report.pdf", "report (1).pdf", "report (2).pdf"...
+func uniqueName(b *bolt.Bucket, name string) string {
+	used := func(name string) bool {
+		return b.Get([]byte(name)) != nil || b.Bucket([]byte(name)) != nil
+	}
+	if !used(name) {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i += 1 {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !used(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// Fork copies element of share into collection under keys in a single transaction
+// from points to element inside the share the same way as keys of GetShared do,
+// empty from means the shared node itself
+// destination should not exist, missing parents are created the same way as Mkdir does,
+// copied names are checked against Store limits, fork is counted as share download
+func (store *Store) Fork(collection string, keys []string, token string, password string, from []string) error {
+	if len(keys) == 0 {
+		return errors.New("destination is not provided")
+	}
+	if err := store.ValidatePath(keys); err != nil {
+		return err
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(collection))
+		if b == nil {
+			return errors.Errorf("bucket \"%s\" not exists", collection)
+		}
+		if err := checkWritable(tx, collection, keys); err != nil {
+			return err
+		}
+
+		record, err := activeShare(tx, token)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrShareNotFound
+		}
+		if err := record.check(password, time.Now()); err != nil {
+			return err
+		}
+		if !record.readable() {
+			return ErrShareForbidden
+		}
+
+		parent, name, err := shareNode(tx, record, from)
+		if err != nil {
+			return err
+		}
+
+		// live share of own folder could not be copied inside of itself
+		if record.Mode == ShareLive && record.Owner == collection && hasPrefix(keys, livePath(record, from)) {
+			return errors.New("folder could not be copied inside of itself")
+		}
+
+		source := parent
+		if name != "" {
+			source = parent.Bucket([]byte(name))
+		}
+		if source != nil {
+			if err := store.validateTree(source, keys); err != nil {
+				return err
+			}
+		}
+
+		for _, key := range keys[:len(keys)-1] {
+			if b.Get([]byte(key)) != nil && b.Bucket([]byte(key)) == nil {
+				return errors.Errorf("name \"%s\" already used", key)
+			}
+			b, err = b.CreateBucketIfNotExists([]byte(key))
+			if err != nil {
+				return errors.Wrap(err, "error creating bucket")
+			}
+		}
+		target := keys[len(keys)-1]
+		if b.Get([]byte(target)) != nil || b.Bucket([]byte(target)) != nil {
+			return errors.Errorf("name \"%s\" already used", target)
+		}
+
+		if source != nil {
+			err = copyBucket(source, b, target)
+		} else {
+			err = b.Put([]byte(target), parent.Get([]byte(name)))
+		}
+		if err != nil {
+			return err
+		}
+
+		record.Accesses += 1
+		return saveShare(tx, record)
+	})
+
+	return errors.Wrap(err, "error forking share")
+}
+
+// shareNode returns bucket, which contains element pointed by keys inside share, and name of that element
+// empty keys point to the shared node itself, empty name is returned for root of share of the whole collection
+func shareNode(tx *bolt.Tx, record *shareRecord, keys []string) (*bolt.Bucket, string, error) {
+	parent, name, err := shareSource(tx, record)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if name == "" && len(keys) == 0 {
+		return parent, "", nil
+	}
+	if name != "" && len(keys) == 0 {
+		keys = []string{name}
+	}
+	if name != "" && keys[0] != name {
+		return nil, "", errors.Errorf("bucket \"%s\" not found", keys[0])
+	}
+
+	for _, key := range keys[:len(keys)-1] {
+		parent = parent.Bucket([]byte(key))
+		if parent == nil {
+			return nil, "", errors.Errorf("bucket \"%s\" not found", key)
+		}
+	}
+
+	last := keys[len(keys)-1]
+	if parent.Bucket([]byte(last)) == nil && parent.Get([]byte(last)) == nil {
+		return nil, "", errors.Errorf("bucket \"%s\" not found", last)
+	}
+
+	return parent, last, nil
+}
+
+// livePath returns path inside owner collection of element pointed by keys inside live share
+func livePath(record *shareRecord, keys []string) []string {
+	if len(record.Path) == 0 {
+		return keys
+	}
+	if len(keys) == 0 {
+		return record.Path
+	}
+
+	path := make([]string, 0, len(record.Path)+len(keys)-1)
+	path = append(path, record.Path[:len(record.Path)-1]...)
+	return append(path, keys...)
+}
+
+// validateTree checks names of all elements inside bucket, as if bucket was placed under keys
+func (store *Store) validateTree(b *bolt.Bucket, keys []string) error {
+	return b.ForEach(func(k, v []byte) error {
+		path := append(keys[:len(keys):len(keys)], string(k))
+		if err := store.ValidatePath(path); err != nil {
+			return err
+		}
+
+		if nested := b.Bucket(k); nested != nil {
+			return store.validateTree(nested, path)
+		}
+		return nil
+	})
+}
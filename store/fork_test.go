@@ -0,0 +1,77 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFork(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Create("friend"))
+	require.Nil(t, s.Put("friend", []string{"taken"}, strings.NewReader("")))
+	require.Nil(t, s.Share("public", []string{"a"}, "snapshot", ShareOptions{}))
+	require.Nil(t, s.Share("public", []string{"1", "2"}, "file", ShareOptions{}))
+	require.Nil(t, s.Share("public", []string{"a", "b"}, "live", ShareOptions{Mode: ShareLive}))
+	require.Nil(t, s.Share("public", []string{"a"}, "protected", ShareOptions{Password: "secret"}))
+	require.Nil(t, s.Share("public", []string{"a"}, "drop", ShareOptions{Mode: ShareDropBox}))
+
+	tt := []struct {
+		Keys   []string
+		Token  string
+		From   []string
+		Result string
+		Error  error
+	}{
+		{[]string{"copy"}, "snapshot", []string{}, "b\n  c\n    Hello there\n", nil},
+		{[]string{"deep", "c"}, "snapshot", []string{"a", "b", "c"}, "Hello there\n", nil},
+		{[]string{"number"}, "file", []string{}, "0", nil},
+		{[]string{"live"}, "live", []string{"b", "c"}, "Hello there\n", nil},
+		{[]string{"copy"}, "file", []string{}, "", errors.New("name \"copy\" already used")},
+		{[]string{"taken", "copy"}, "file", []string{}, "", errors.New("name \"taken\" already used")},
+		{[]string{"other"}, "snapshot", []string{"b"}, "", errors.New("bucket \"b\" not found")},
+		{[]string{"other"}, "missing", []string{}, "", ErrShareNotFound},
+		{[]string{"other"}, "protected", []string{}, "", ErrSharePassword},
+		{[]string{"other"}, "drop", []string{}, "", ErrShareForbidden},
+	}
+
+	for _, test := range tt {
+		err := s.Fork("friend", test.Keys, test.Token, "", test.From)
+		if test.Error != nil {
+			require.NotNil(t, err)
+			assert.Equal(t, test.Error.Error(), errors.Cause(err).Error())
+			continue
+		}
+		require.Nil(t, err)
+
+		result, err := s.Get("friend", test.Keys)
+		require.Nil(t, err)
+		assert.Equal(t, test.Result, string(result))
+	}
+
+	// fork is counted as download
+	share, err := s.GetShare("public", "snapshot")
+	require.Nil(t, err)
+	assert.Equal(t, 2, share.Accesses)
+
+	// copy is independent from source
+	require.Nil(t, s.Put("public", []string{"a", "b", "c", "Hello there"}, strings.NewReader("changed")))
+	result, err := s.Get("friend", []string{"live", "Hello there"})
+	require.Nil(t, err)
+	assert.Equal(t, "General Kenobi", string(result))
+
+	// own live share could not be copied inside of itself
+	assert.NotNil(t, s.Fork("public", []string{"a", "b", "copy"}, "live", "", []string{}))
+	require.Nil(t, s.Fork("public", []string{"copy"}, "live", "", []string{}))
+
+	// copied names are checked against limits
+	s.MaxDepth = 3
+	err = s.Fork("friend", []string{"limited"}, "snapshot", "", []string{})
+	assert.Equal(t, ErrInvalidPath, errors.Cause(err))
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInviteNotFound is returned for unknown and revoked invite codes
+	ErrInviteNotFound  = errors.New("invite not found")
+	ErrInviteExpired   = errors.New("invite expired")
+	ErrInviteExhausted = errors.New("invite has no uses left")
+	ErrInviteAllowance = errors.New("invite allowance exceeded")
+)
+
+// Invite lets to register without being whitelisted
+type Invite struct {
+	Code string `json:"code"`
+	// Owner is account, which created the invite
+	Owner   string `json:"owner"`
+	MaxUses int    `json:"max_uses"`
+	// Uses records accounts registered with the invite
+	Uses    []InviteUse `json:"uses"`
+	Revoked bool        `json:"revoked"`
+	Expires time.Time   `json:"expires,omitempty"`
+	Created time.Time   `json:"created"`
+}
+
+// InviteUse describes registration made with invite
+type InviteUse struct {
+	Account string    `json:"account"`
+	Email   string    `json:"email"`
+	Used    time.Time `json:"used"`
+}
+
+// check returns reason, why invite could not be used
+func (invite *Invite) check(now time.Time) error {
+	if invite.Revoked {
+		return ErrInviteNotFound
+	}
+	if !invite.Expires.IsZero() && now.After(invite.Expires) {
+		return ErrInviteExpired
+	}
+	if len(invite.Uses) >= invite.MaxUses {
+		return ErrInviteExhausted
+	}
+	return nil
+}
+
+// seats returns number of registrations invite takes from allowance of its owner
+// invites, which could not be used anymore, take only used seats
+func (invite *Invite) seats(now time.Time) int {
+	if invite.check(now) != nil {
+		return len(invite.Uses)
+	}
+	return invite.MaxUses
+}
+
+// CreateInvite creates invite code of account, which could be used maxUses times before expiration
+// total number of registrations by invites of one account is limited by allowance, negative allowance means no limit
+func (store *Store) CreateInvite(owner string, code string, maxUses int, expires time.Time, allowance int) (*Invite, error) {
+	if maxUses < 1 {
+		return nil, errors.New("invite should have at least one use")
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	now := time.Now()
+	invite := &Invite{Code: code, Owner: owner, MaxUses: maxUses, Uses: []InviteUse{}, Expires: expires, Created: now}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if allowance >= 0 {
+			seats := maxUses
+			err := forEachInvite(tx, func(existing *Invite) error {
+				if existing.Owner == owner {
+					seats += existing.seats(now)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if seats > allowance {
+				return ErrInviteAllowance
+			}
+		}
+
+		existing, err := loadInvite(tx, code)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return errors.New("invite code already used")
+		}
+
+		return saveInvite(tx, invite)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating invite")
+	}
+
+	return invite, nil
+}
+
+// CheckInvite returns error, when invite could not be used for registration
+func (store *Store) CheckInvite(code string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		invite, err := loadInvite(tx, code)
+		if err != nil {
+			return err
+		}
+		if invite == nil {
+			return ErrInviteNotFound
+		}
+		return invite.check(time.Now())
+	})
+
+	return errors.Wrap(err, "error checking invite")
+}
+
+// ListInvites returns invites of account, all invites are returned for empty owner
+func (store *Store) ListInvites(owner string) ([]*Invite, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	invites := make([]*Invite, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		return forEachInvite(tx, func(invite *Invite) error {
+			if owner == "" || invite.Owner == owner {
+				invites = append(invites, invite)
+			}
+			return nil
+		})
+	})
+
+	sort.Slice(invites, func(i, j int) bool { return invites[i].Created.Before(invites[j].Created) })
+	return invites, errors.Wrap(err, "error listing invites")
+}
+
+// RevokeInvite stops invite of account from being used, registrations already made with it are kept in its history
+func (store *Store) RevokeInvite(owner string, code string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		invite, err := loadInvite(tx, code)
+		if err != nil {
+			return err
+		}
+		if invite == nil || invite.Revoked || invite.Owner != owner {
+			return ErrInviteNotFound
+		}
+
+		invite.Revoked = true
+		return saveInvite(tx, invite)
+	})
+
+	return errors.Wrap(err, "error revoking invite")
+}
+
+// useInvite records registration of account with invite
+func useInvite(tx *bolt.Tx, code string, account *accountRecord) error {
+	invite, err := loadInvite(tx, code)
+	if err != nil {
+		return err
+	}
+	if invite == nil {
+		return ErrInviteNotFound
+	}
+	if err := invite.check(time.Now()); err != nil {
+		return err
+	}
+
+	invite.Uses = append(invite.Uses, InviteUse{Account: account.ID, Email: account.Email, Used: time.Now()})
+	if err := saveInvite(tx, invite); err != nil {
+		return err
+	}
+
+	account.InvitedBy = invite.Owner
+	return saveAccount(tx, account)
+}
+
+// forEachInvite calls fn for every invite
+func forEachInvite(tx *bolt.Tx, fn func(invite *Invite) error) error {
+	b := system(tx, invitesBucket)
+	if b == nil {
+		return nil
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		invite := &Invite{}
+		if err := json.Unmarshal(v, invite); err != nil {
+			return errors.Wrap(err, "error decoding invite")
+		}
+		return fn(invite)
+	})
+}
+
+// loadInvite returns decoded invite, nil is returned for unknown code
+func loadInvite(tx *bolt.Tx, code string) (*Invite, error) {
+	b := system(tx, invitesBucket)
+	if b == nil {
+		return nil, nil
+	}
+
+	v := b.Get([]byte(code))
+	if v == nil {
+		return nil, nil
+	}
+
+	invite := &Invite{}
+	err := json.Unmarshal(v, invite)
+	return invite, errors.Wrap(err, "error decoding invite")
+}
+
+func saveInvite(tx *bolt.Tx, invite *Invite) error {
+	b, err := createSystem(tx, invitesBucket)
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(invite)
+	if err != nil {
+		return errors.Wrap(err, "error encoding invite")
+	}
+
+	return errors.Wrap(b.Put([]byte(invite.Code), v), "error saving invite")
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvite(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	owner, err := s.CreateAccount("morpheus", "morpheus@zion.org", "morpheus token")
+	require.Nil(t, err)
+
+	_, err = s.CreateInvite(owner.ID, "crew", 2, time.Time{}, 3)
+	require.Nil(t, err)
+	_, err = s.CreateInvite(owner.ID, "too many", 2, time.Time{}, 3)
+	assert.Equal(t, ErrInviteAllowance, errors.Cause(err))
+	_, err = s.CreateInvite(owner.ID, "expired", 1, time.Now().Add(-time.Minute), 3)
+	require.Nil(t, err)
+	// expired invite does not take allowance
+	_, err = s.CreateInvite(owner.ID, "single", 1, time.Now().Add(time.Hour), 3)
+	require.Nil(t, err)
+	_, err = s.CreateInvite(owner.ID, "unlimited", 10, time.Time{}, -1)
+	require.Nil(t, err)
+	_, err = s.CreateInvite(owner.ID, "zero", 0, time.Time{}, -1)
+	assert.NotNil(t, err)
+
+	tt := []struct {
+		Code string
+		Err  error
+	}{
+		{"crew", nil},
+		{"single", nil},
+		{"expired", ErrInviteExpired},
+		{"missing", ErrInviteNotFound},
+	}
+
+	for _, test := range tt {
+		assert.Equal(t, test.Err, errors.Cause(s.CheckInvite(test.Code)), test.Code)
+	}
+
+	_, err = s.CreateSignup("neo signup", "neo@matrix.com", "single", "", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	_, err = s.CreateSignup("trinity signup", "trinity@matrix.com", "single", "", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+
+	neo, err := s.ConfirmSignup("neo signup", "neo", "neo token")
+	require.Nil(t, err)
+	assert.Equal(t, owner.ID, neo.InvitedBy)
+	assert.Equal(t, ErrInviteExhausted, errors.Cause(s.CheckInvite("single")))
+
+	// invite used up by another registration keeps the signup from confirming
+	_, err = s.ConfirmSignup("trinity signup", "trinity", "trinity token")
+	assert.Equal(t, ErrInviteExhausted, errors.Cause(err))
+	_, err = s.GetAccount("trinity")
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(err))
+
+	account, err := s.GetAccount("neo")
+	require.Nil(t, err)
+	assert.Equal(t, owner.ID, account.InvitedBy)
+
+	assert.Equal(t, ErrInviteNotFound, errors.Cause(s.RevokeInvite("neo", "crew")))
+	require.Nil(t, s.RevokeInvite(owner.ID, "crew"))
+	assert.Equal(t, ErrInviteNotFound, errors.Cause(s.CheckInvite("crew")))
+
+	invites, err := s.ListInvites(owner.ID)
+	require.Nil(t, err)
+	require.Len(t, invites, 4)
+	assert.Equal(t, "crew", invites[0].Code)
+	assert.True(t, invites[0].Revoked)
+	assert.Equal(t, "single", invites[2].Code)
+	require.Len(t, invites[2].Uses, 1)
+	assert.Equal(t, "neo", invites[2].Uses[0].Account)
+	assert.Equal(t, "neo@matrix.com", invites[2].Uses[0].Email)
+
+	invites, err = s.ListInvites("neo")
+	require.Nil(t, err)
+	assert.Len(t, invites, 0)
+}
@@ -0,0 +1,336 @@
+package store
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrMountNotFound = errors.New("mount not found")
+	ErrMountReadOnly = errors.New("mounted share is read-only")
+	// ErrMountBroken is returned when mounted share was revoked or removed after expiration
+	ErrMountBroken = errors.New("mounted share no longer exists")
+)
+
+// MountInfo describes share of other user, attached to collection tree
+type MountInfo struct {
+	// Path is mount point inside collection
+	Path    []string  `json:"path"`
+	Token   string    `json:"token"`
+	Created time.Time `json:"created"`
+}
+
+// mountRecord is stored form of mount info
+// password of protected share is checked once on mounting and never kept, only fingerprint of share password hash is,
+// so mount stops working when share password changes
+type mountRecord struct {
+	MountInfo
+	PasswordFingerprint string `json:"password_fingerprint,omitempty"`
+}
+
+// Mount attaches share given by token at keys inside collection
+// mounted share is read-only, reads go through share restrictions the same way as GetShared does
+func (store *Store) Mount(collection string, keys []string, token string, password string) (*MountInfo, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("mount path is not provided")
+	}
+	if err := store.ValidatePath(keys); err != nil {
+		return nil, err
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	mount := &mountRecord{
+		MountInfo: MountInfo{Path: keys, Token: token, Created: time.Now()},
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(collection))
+		if b == nil {
+			return errors.Errorf("bucket \"%s\" not exists", collection)
+		}
+
+		record, err := activeShare(tx, token)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrShareNotFound
+		}
+		if err := record.check(password, time.Now()); err != nil {
+			return err
+		}
+		if !record.readable() {
+			return ErrShareForbidden
+		}
+		if record.Protected {
+			mount.PasswordFingerprint = passwordFingerprint(record.PasswordHash)
+		}
+
+		// mounts could not be nested
+		err = forEachMount(tx, collection, func(other *mountRecord) error {
+			if hasPrefix(keys, other.Path) || hasPrefix(other.Path, keys) {
+				return errors.Errorf("path overlaps mount \"%s\"", strings.Join(other.Path, "/"))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// missing parents are created like Mkdir does, but mount point itself should be free
+		for _, key := range keys[:len(keys)-1] {
+			if b.Get([]byte(key)) != nil && b.Bucket([]byte(key)) == nil {
+				return errors.Errorf("name \"%s\" already used", key)
+			}
+			b, err = b.CreateBucketIfNotExists([]byte(key))
+			if err != nil {
+				return errors.Wrap(err, "error creating bucket")
+			}
+		}
+		name := keys[len(keys)-1]
+		if b.Get([]byte(name)) != nil || b.Bucket([]byte(name)) != nil {
+			return errors.Errorf("name \"%s\" already used", name)
+		}
+
+		return saveMount(tx, collection, mount)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error mounting share")
+	}
+
+	return &mount.MountInfo, nil
+}
+
+// ListMounts returns all mounts of collection
+func (store *Store) ListMounts(collection string) ([]*MountInfo, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	mounts := make([]*MountInfo, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		return forEachMount(tx, collection, func(mount *mountRecord) error {
+			mounts = append(mounts, &mount.MountInfo)
+			return nil
+		})
+	})
+
+	return mounts, errors.Wrap(err, "error listing mounts")
+}
+
+// Unmount detaches share mounted exactly at keys, shared data is not touched
+func (store *Store) Unmount(collection string, keys []string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		mount, err := findMount(tx, collection, keys)
+		if err != nil {
+			return err
+		}
+		if mount == nil || len(mount.Path) != len(keys) {
+			return ErrMountNotFound
+		}
+
+		return deleteMount(tx, collection, mount.Path)
+	})
+
+	return errors.Wrap(err, "error unmounting share")
+}
+
+// readMount reads element of mounted share, keys are relative to mount point
+// file reads are counted in share info, like reads by share token are
+func readMount(tx *bolt.Tx, mount *mountRecord, keys []string) ([]byte, error) {
+	record, err := activeShare(tx, mount.Token)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrMountBroken
+	}
+
+	unlocked := !record.Protected || subtle.ConstantTimeCompare(
+		[]byte(passwordFingerprint(record.PasswordHash)), []byte(mount.PasswordFingerprint)) == 1
+	if err := record.checkAccess(unlocked, time.Now()); err != nil {
+		return nil, err
+	}
+
+	node, err := countedRead(tx, record, keys)
+	if errors.Cause(err) == ErrShareNotFound {
+		return nil, ErrMountBroken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Data, nil
+}
+
+// passwordFingerprint identifies password hash of share without keeping the hash itself
+// hash is salted on every change, so fingerprint changes together with password
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte("mount:" + hash))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkWritable returns error, when keys point to mount point or inside of it
+func checkWritable(tx *bolt.Tx, collection string, keys []string) error {
+	mount, err := findMount(tx, collection, keys)
+	if err != nil {
+		return err
+	}
+	if mount != nil {
+		return ErrMountReadOnly
+	}
+
+	return nil
+}
+
+// findMount returns mount, which contains element pointed by keys, nil is returned if there is no such mount
+func findMount(tx *bolt.Tx, collection string, keys []string) (*mountRecord, error) {
+	var found *mountRecord
+	err := forEachMount(tx, collection, func(mount *mountRecord) error {
+		if hasPrefix(keys, mount.Path) {
+			found = mount
+		}
+		return nil
+	})
+
+	return found, err
+}
+
+// detachMounts removes mounts placed inside element pointed by keys
+// it is used when element is deleted, so mounts do not outlive their parents
+func detachMounts(tx *bolt.Tx, collection string, keys []string) error {
+	// paths are collected first, because bucket should not be modified while iterating
+	paths := make([][]string, 0)
+	err := forEachMount(tx, collection, func(mount *mountRecord) error {
+		if hasPrefix(mount.Path, keys) {
+			paths = append(paths, mount.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := deleteMount(tx, collection, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mountedView returns paths of mounts of collection under mountedEntry
+func mountedView(tx *bolt.Tx, collection string, indent string) (string, error) {
+	result := ""
+	err := forEachMount(tx, collection, func(mount *mountRecord) error {
+		result += indent + "  " + mountKey(mount.Path) + "\n"
+		return nil
+	})
+	if result == "" {
+		return "", err
+	}
+
+	return indent + mountedEntry + "\n" + result, err
+}
+
+// forEachMount calls fn for every mount of collection
+// mounts of every collection are kept in separate child of system mounts bucket
+func forEachMount(tx *bolt.Tx, collection string, fn func(mount *mountRecord) error) error {
+	mounts := system(tx, mountsBucket)
+	if mounts == nil {
+		return nil
+	}
+	b := mounts.Bucket([]byte(collection))
+	if b == nil {
+		return nil
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		mount := &mountRecord{}
+		if err := json.Unmarshal(v, mount); err != nil {
+			return errors.Wrap(err, "error decoding mount info")
+		}
+		return fn(mount)
+	})
+}
+
+func saveMount(tx *bolt.Tx, collection string, mount *mountRecord) error {
+	mounts, err := createSystem(tx, mountsBucket)
+	if err != nil {
+		return err
+	}
+	b, err := mounts.CreateBucketIfNotExists([]byte(collection))
+	if err != nil {
+		return errors.Wrap(err, "error creating mounts bucket")
+	}
+
+	v, err := json.Marshal(mount)
+	if err != nil {
+		return errors.Wrap(err, "error encoding mount info")
+	}
+
+	return errors.Wrap(b.Put([]byte(mountKey(mount.Path)), v), "error saving mount")
+}
+
+func deleteMount(tx *bolt.Tx, collection string, path []string) error {
+	mounts := system(tx, mountsBucket)
+	if mounts == nil || mounts.Bucket([]byte(collection)) == nil {
+		return nil
+	}
+
+	return errors.Wrap(mounts.Bucket([]byte(collection)).Delete([]byte(mountKey(path))), "error deleting mount")
+}
+
+// deleteMounts removes all mounts of collection
+func deleteMounts(tx *bolt.Tx, collection string) error {
+	mounts := system(tx, mountsBucket)
+	if mounts == nil || mounts.Bucket([]byte(collection)) == nil {
+		return nil
+	}
+
+	return errors.Wrap(mounts.DeleteBucket([]byte(collection)), "error deleting mounts")
+}
+
+// mountKey joins escaped names, so every path has its own key
+func mountKey(path []string) string {
+	names := make([]string, len(path))
+	for i, name := range path {
+		names[i] = EscapeName(name)
+	}
+
+	return strings.Join(names, "/")
+}
+
+// hasPrefix reports if keys start with prefix
+func hasPrefix(keys []string, prefix []string) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+	for i := range prefix {
+		if keys[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMount(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Create("friend"))
+	require.Nil(t, s.Share("public", []string{"a"}, "folder", ShareOptions{}))
+	require.Nil(t, s.Share("public", []string{"1", "2"}, "file", ShareOptions{Mode: ShareLive}))
+	require.Nil(t, s.Share("public", []string{"1"}, "protected", ShareOptions{Password: "secret"}))
+	require.Nil(t, s.Share("public", []string{"1"}, "drop", ShareOptions{Mode: ShareDropBox}))
+
+	_, err = s.Mount("friend", []string{"from", "public"}, "folder", "")
+	require.Nil(t, err)
+	_, err = s.Mount("friend", []string{"number"}, "file", "")
+	require.Nil(t, err)
+
+	// invalid mounts
+	_, err = s.Mount("friend", []string{"other"}, "missing", "")
+	assert.Equal(t, ErrShareNotFound, errors.Cause(err))
+	_, err = s.Mount("friend", []string{"other"}, "protected", "wrong")
+	assert.Equal(t, ErrSharePassword, errors.Cause(err))
+	_, err = s.Mount("friend", []string{"other"}, "drop", "")
+	assert.Equal(t, ErrShareForbidden, errors.Cause(err))
+	_, err = s.Mount("friend", []string{"from"}, "file", "")
+	assert.NotNil(t, err)
+	_, err = s.Mount("friend", []string{"from", "public", "inner"}, "file", "")
+	assert.NotNil(t, err)
+	_, err = s.Mount("friend", []string{"protected"}, "protected", "secret")
+	require.Nil(t, err)
+
+	tt := []struct {
+		Keys   []string
+		Result string
+	}{
+		{[]string{}, "from/\n/mounted\n  from/public\n  number\n  protected\n"},
+		{[]string{"from", "public"}, "a\n  b\n    c\n      Hello there\n"},
+		{[]string{"from", "public", "a", "b", "c", "Hello there"}, "General Kenobi"},
+		{[]string{"number"}, "0"},
+		{[]string{"protected", "1", "2"}, "0"},
+	}
+
+	for _, test := range tt {
+		result, err := s.Get("friend", test.Keys)
+		require.Nil(t, err)
+		assert.Equal(t, test.Result, string(result))
+	}
+
+	// file reads through mount are counted, folder listings are not
+	share, err := s.GetShare("public", "folder")
+	require.Nil(t, err)
+	assert.Equal(t, 1, share.Accesses)
+
+	// mounts are read-only
+	assert.Equal(t, ErrMountReadOnly, errors.Cause(s.Put("friend", []string{"from", "public", "new"}, strings.NewReader(""))))
+	assert.Equal(t, ErrMountReadOnly, errors.Cause(s.Put("friend", []string{"number"}, strings.NewReader(""))))
+	assert.Equal(t, ErrMountReadOnly, errors.Cause(s.Mkdir("friend", []string{"from", "public", "new"})))
+	assert.Equal(t, ErrMountReadOnly, errors.Cause(s.Delete("friend", []string{"from", "public", "a"})))
+	_, err = s.Append("friend", []string{"number"}, strings.NewReader("1"))
+	assert.Equal(t, ErrMountReadOnly, errors.Cause(err))
+	_, err = s.WriteAt("friend", []string{"number"}, 0, strings.NewReader("1"))
+	assert.Equal(t, ErrMountReadOnly, errors.Cause(err))
+
+	// revoked share
+	require.Nil(t, s.RevokeShare("public", "file"))
+	_, err = s.Get("friend", []string{"number"})
+	assert.Equal(t, ErrMountBroken, errors.Cause(err))
+
+	// delete only detaches mount
+	require.Nil(t, s.Delete("friend", []string{"number"}))
+	_, err = s.Get("friend", []string{"number"})
+	assert.NotNil(t, err)
+	assert.Equal(t, ErrMountNotFound, errors.Cause(s.Unmount("friend", []string{"number"})))
+	result, err := s.Get("public", []string{"a", "b", "c", "Hello there"})
+	require.Nil(t, err)
+	assert.Equal(t, "General Kenobi", string(result))
+
+	// mounts inside deleted folder are detached
+	require.Nil(t, s.Delete("friend", []string{"from"}))
+	mounts, err := s.ListMounts("friend")
+	require.Nil(t, err)
+	require.Len(t, mounts, 1)
+	assert.Equal(t, []string{"protected"}, mounts[0].Path)
+	assert.Equal(t, "protected", mounts[0].Token)
+}
+
+func TestMountPassword(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Create("friend"))
+	require.Nil(t, s.Share("public", []string{"1"}, "protected", ShareOptions{Password: "secret"}))
+	_, err = s.Mount("friend", []string{"protected"}, "protected", "secret")
+	require.Nil(t, err)
+
+	// password itself is not stored with mount
+	db, err := s.open()
+	require.Nil(t, err)
+	err = db.View(func(tx *bolt.Tx) error {
+		return system(tx, mountsBucket).Bucket([]byte("friend")).ForEach(func(k, v []byte) error {
+			assert.NotContains(t, string(v), "secret", string(k))
+			return nil
+		})
+	})
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+
+	result, err := s.Get("friend", []string{"protected", "1", "2"})
+	require.Nil(t, err)
+	assert.Equal(t, "0", string(result))
+
+	// changed share password invalidates mounts
+	db, err = s.open()
+	require.Nil(t, err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := loadShare(tx, "protected")
+		if err != nil {
+			return err
+		}
+		record.PasswordHash = hashPassword("secret")
+		return saveShare(tx, record)
+	})
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+
+	_, err = s.Get("friend", []string{"protected", "1", "2"})
+	assert.Equal(t, ErrSharePassword, errors.Cause(err))
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// MinPasswordLength is minimal length of account password in bytes
+const MinPasswordLength = 8
+
+// passwordIterations is number of PBKDF2 rounds, it makes every guess slow
+const passwordIterations = 100000
+
+var (
+	ErrWeakPassword = errors.New("password is too short")
+	// ErrInvalidCredentials is returned for unknown email, wrong password and accounts without password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// SetPassword sets password of account, so it could log in with email and password
+// all sessions of account are closed
+func (store *Store) SetPassword(id string, password string) error {
+	hash, err := hashAccountPassword(password)
+	if err != nil {
+		return err
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := loadAccount(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+		if record.Email == "" {
+			return errors.New("account without email could not have password")
+		}
+
+		record.PasswordHash = hash
+		if err := saveAccount(tx, record); err != nil {
+			return err
+		}
+		return deleteSessions(tx, func(session *Session) bool { return session.Account == id })
+	})
+
+	return errors.Wrap(err, "error setting password")
+}
+
+// Login returns account with given email and password
+func (store *Store) Login(email string, password string) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var record *accountRecord
+	err = db.View(func(tx *bolt.Tx) error {
+		record, err = accountByEmail(tx, email)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error logging in")
+	}
+
+	if record == nil || record.PasswordHash == "" {
+		// hash is computed anyway, so response time does not tell if email is registered
+		hashAccountPassword(password)
+		return nil, ErrInvalidCredentials
+	}
+	if !checkPassword(record.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	if record.Suspended {
+		return nil, ErrAccountSuspended
+	}
+
+	return &record.Account, nil
+}
+
+// hashAccountPassword hashes account password, which should be at least MinPasswordLength long
+// share passwords use the same hash, but have no length rule, they are chosen by share owner for single share
+func hashAccountPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrWeakPassword
+	}
+
+	return hashPassword(password), nil
+}
+
+// hashPassword returns salted PBKDF2-SHA256 hash in form of "<iterations>:<salt>:<hash>"
+// slow hash is used, so leaked database does not make guessing passwords cheap
+func hashPassword(password string) string {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	sum := pbkdf2([]byte(password), salt, passwordIterations)
+
+	return strconv.Itoa(passwordIterations) + ":" + hex.EncodeToString(salt) + ":" + hex.EncodeToString(sum)
+}
+
+// checkPassword compares password with hash created by hashPassword in constant time
+func checkPassword(hash string, password string) bool {
+	parts := strings.SplitN(hash, ":", 3)
+	if len(parts) != 3 {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[0])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	sum := pbkdf2([]byte(password), salt, iterations)
+
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum)), []byte(parts[2])) == 1
+}
+
+// pbkdf2 derives single block of PBKDF2 with HMAC-SHA256 (RFC 8018), which is 32 bytes long
+func pbkdf2(password []byte, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, password)
+	block := make([]byte, 4)
+	binary.BigEndian.PutUint32(block, 1)
+
+	prf.Write(salt)
+	prf.Write(block)
+	u := prf.Sum(nil)
+	result := make([]byte, len(u))
+	copy(result, u)
+
+	for i := 1; i < iterations; i += 1 {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// default limits for names, used when Store fields are not set
+const (
+	DefaultMaxNameLength = 255
+	DefaultMaxDepth      = 32
+)
+
+// ErrInvalidPath is returned for names, which could not be stored
+var ErrInvalidPath = errors.New("invalid path")
+
+// ParsePath converts escaped path (like "/a/b%2Fc") into list of names (["a", "b/c"])
+// every segment is unescaped separately, so names could contain any character, including "/"
+// empty segments are skipped, "a//b" leads to ["a", "b"]
+func (store *Store) ParsePath(escaped string) ([]string, error) {
+	keys := make([]string, 0)
+	for _, segment := range strings.Split(escaped, "/") {
+		if segment == "" {
+			continue
+		}
+
+		key, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidPath, "cannot unescape \"%s\"", segment)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, store.ValidatePath(keys)
+}
+
+// ValidatePath checks if names could be written to database
+// names should be valid UTF-8 without control characters, "." and ".." are not allowed
+// length of each name and number of names are limited by Store settings
+func (store *Store) ValidatePath(keys []string) error {
+	maxDepth := store.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if len(keys) > maxDepth {
+		return errors.Wrapf(ErrInvalidPath, "path is deeper than %d", maxDepth)
+	}
+
+	maxNameLength := store.MaxNameLength
+	if maxNameLength == 0 {
+		maxNameLength = DefaultMaxNameLength
+	}
+
+	for _, key := range keys {
+		switch {
+		case key == "":
+			return errors.Wrap(ErrInvalidPath, "empty name")
+		case key == "." || key == "..":
+			return errors.Wrapf(ErrInvalidPath, "name \"%s\" is not allowed", key)
+		case len(key) > maxNameLength:
+			return errors.Wrapf(ErrInvalidPath, "name is longer than %d bytes", maxNameLength)
+		case !utf8.ValidString(key):
+			return errors.Wrapf(ErrInvalidPath, "name \"%s\" is not valid UTF-8", EscapeName(key))
+		case strings.IndexFunc(key, unicode.IsControl) != -1:
+			return errors.Wrapf(ErrInvalidPath, "name \"%s\" contains control characters", EscapeName(key))
+		}
+	}
+
+	return nil
+}
+
+// EscapeName makes name safe for putting into path or tree view
+// "%", "/", control characters and invalid UTF-8 bytes are percent-encoded,
+// everything else is kept as is. Result could be reverted with UnescapeName
+func EscapeName(name string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1,
+			r == '%', r == '/', unicode.IsControl(r):
+			for _, b := range []byte(name[i : i+size]) {
+				fmt.Fprintf(&escaped, "%%%02X", b)
+			}
+		default:
+			escaped.WriteString(name[i : i+size])
+		}
+		i += size
+	}
+
+	return escaped.String()
+}
+
+// UnescapeName reverts EscapeName
+func UnescapeName(escaped string) (string, error) {
+	name, err := url.PathUnescape(escaped)
+	return name, errors.Wrap(err, "error unescaping name")
+}
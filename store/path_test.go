@@ -0,0 +1,113 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	tt := []struct {
+		Path  string
+		Keys  []string
+		Valid bool
+	}{
+		{"", []string{}, true},
+		{"/", []string{}, true},
+		{"a///b/c/", []string{"a", "b", "c"}, true},
+		{"/a%2Fb/c", []string{"a/b", "c"}, true},
+		{"/%25/100%25", []string{"%", "100%"}, true},
+		{"/Hello%20there/%E2%9C%93", []string{"Hello there", "✓"}, true},
+		{"/a%2F%2F", []string{"a//"}, true},
+		{"/.../..a", []string{"...", "..a"}, true},
+		{"/./a", nil, false},
+		{"/a/..", nil, false},
+		{"/%2E%2E/a", nil, false},
+		{"/a%0Ab", nil, false},
+		{"/a%00b", nil, false},
+		{"/%7F", nil, false},
+		{"/%C2%85", nil, false},
+		{"/%FF%FE", nil, false},
+		{"/%zz", nil, false},
+		{"/%", nil, false},
+		{"/" + strings.Repeat("a", 256), nil, false},
+		{strings.Repeat("/a", 33), nil, false},
+	}
+
+	s := &Store{}
+	for _, test := range tt {
+		keys, err := s.ParsePath(test.Path)
+		if !test.Valid {
+			assert.Equal(t, ErrInvalidPath, errors.Cause(err), test.Path)
+			continue
+		}
+		require.Nil(t, err, test.Path)
+		assert.Equal(t, test.Keys, keys)
+	}
+}
+
+func TestPathLimits(t *testing.T) {
+	s := &Store{MaxNameLength: 3, MaxDepth: 2}
+
+	assert.Nil(t, s.ValidatePath([]string{"abc", "✓"}))
+	assert.NotNil(t, s.ValidatePath([]string{"abcd"}))
+	assert.NotNil(t, s.ValidatePath([]string{"✓✓"}))
+	assert.NotNil(t, s.ValidatePath([]string{"a", "b", "c"}))
+}
+
+func TestEscapeName(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Escaped string
+	}{
+		{"The Ring", "The Ring"},
+		{"a/b", "a%2Fb"},
+		{"100%", "100%25"},
+		{"line\nbreak", "line%0Abreak"},
+		{"tab\t", "tab%09"},
+		{"ünïcödé ✓", "ünïcödé ✓"},
+		{"\xff\xfe", "%FF%FE"},
+		{"\u0085", "%C2%85"},
+	}
+
+	for _, test := range tt {
+		escaped := EscapeName(test.Name)
+		assert.Equal(t, test.Escaped, escaped)
+		assert.NotContains(t, escaped, "\n")
+
+		name, err := UnescapeName(escaped)
+		require.Nil(t, err)
+		assert.Equal(t, test.Name, name)
+	}
+}
+
+func TestHostileNames(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	keys, err := s.ParsePath("/dir%2Fwith%2Fslashes/100%25 done")
+	require.Nil(t, err)
+	err = s.Put("public", keys, strings.NewReader("content"))
+	require.Nil(t, err)
+
+	result, err := s.Get("public", []string{"dir/with/slashes", "100% done"})
+	require.Nil(t, err)
+	assert.Equal(t, "content", string(result))
+
+	result, err = s.Get("public", []string{"dir/with/slashes"})
+	require.Nil(t, err)
+	assert.Equal(t, "100%25 done\n", string(result))
+
+	for _, keys := range [][]string{{".."}, {"a", "."}, {"new\nline"}, {"\x1b[31mred"}} {
+		err = s.Put("public", keys, strings.NewReader("content"))
+		assert.Equal(t, ErrInvalidPath, errors.Cause(err))
+		err = s.Mkdir("public", keys)
+		assert.Equal(t, ErrInvalidPath, errors.Cause(err))
+		_, err = s.WriteAt("public", keys, 0, strings.NewReader("content"))
+		assert.Equal(t, ErrInvalidPath, errors.Cause(err))
+	}
+}
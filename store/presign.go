@@ -0,0 +1,102 @@
+package store
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// ErrPresignUsed is returned for presigned url, which was already used once
+var ErrPresignUsed = errors.New("presigned url already used")
+
+// UsePresigned remembers signature of presigned url, so the url works only once
+// signatures are kept until expiration of url, later the url is rejected as expired anyway
+func (store *Store) UsePresigned(signature string, expires time.Time) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		used, err := createSystem(tx, presignedBucket)
+		if err != nil {
+			return err
+		}
+		key := []byte(hashToken(signature))
+		if used.Get(key) != nil {
+			return ErrPresignUsed
+		}
+
+		v, err := expires.MarshalText()
+		if err != nil {
+			return errors.Wrap(err, "error encoding expiration time")
+		}
+		return errors.Wrap(used.Put(key, v), "error saving presigned url")
+	})
+
+	return errors.Wrap(err, "error using presigned url")
+}
+
+// ReleasePresigned forgets signature remembered by UsePresigned, so the url could be used again
+// it is called when request made with the url failed
+func (store *Store) ReleasePresigned(signature string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		used := system(tx, presignedBucket)
+		if used == nil {
+			return nil
+		}
+		return used.Delete([]byte(hashToken(signature)))
+	})
+
+	return errors.Wrap(err, "error releasing presigned url")
+}
+
+// DeleteExpiredPresigned forgets signatures of expired presigned urls
+func (store *Store) DeleteExpiredPresigned() error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	now := time.Now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		used := system(tx, presignedBucket)
+		if used == nil {
+			return nil
+		}
+
+		// keys are collected first, because bucket should not be modified while iterating
+		expired := make([][]byte, 0)
+		err := used.ForEach(func(k, v []byte) error {
+			expires := time.Time{}
+			if err := expires.UnmarshalText(v); err != nil {
+				return errors.Wrap(err, "error decoding expiration time")
+			}
+			if now.After(expires) {
+				expired = append(expired, copyValue(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := used.Delete(k); err != nil {
+				return errors.Wrap(err, "error deleting presigned url")
+			}
+		}
+		return nil
+	})
+
+	return errors.Wrap(err, "error deleting expired presigned urls")
+}
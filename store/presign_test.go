@@ -0,0 +1,30 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsePresigned(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.UsePresigned("active", time.Now().Add(time.Hour)))
+	assert.Equal(t, ErrPresignUsed, errors.Cause(s.UsePresigned("active", time.Now().Add(time.Hour))))
+	require.Nil(t, s.UsePresigned("expired", time.Now().Add(-time.Minute)))
+
+	// only expired signatures are forgotten
+	require.Nil(t, s.DeleteExpiredPresigned())
+	assert.Equal(t, ErrPresignUsed, errors.Cause(s.UsePresigned("active", time.Now().Add(time.Hour))))
+	assert.Nil(t, s.UsePresigned("expired", time.Now().Add(-time.Minute)))
+
+	// released url could be used again
+	require.Nil(t, s.ReleasePresigned("active"))
+	assert.Nil(t, s.UsePresigned("active", time.Now().Add(time.Hour)))
+	assert.Equal(t, ErrPresignUsed, errors.Cause(s.UsePresigned("active", time.Now().Add(time.Hour))))
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrSessionNotFound is returned for unknown and closed sessions
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session expired")
+)
+
+// Session is login of account by password, it is identified by random ID kept in cookie
+// only hash of ID is stored, the same way as for tokens
+type Session struct {
+	Account string `json:"account"`
+	// CSRF is token, which should accompany requests changing data
+	CSRF    string    `json:"csrf"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// CreateSession opens session of account, which lasts until expires
+func (store *Store) CreateSession(account string, id string, csrf string, expires time.Time) (*Session, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	session := &Session{Account: account, CSRF: csrf, Created: time.Now(), Expires: expires}
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := loadAccount(tx, account)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrAccountNotFound
+		}
+
+		sessions, err := createSystem(tx, sessionsBucket)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(session)
+		if err != nil {
+			return errors.Wrap(err, "error encoding session")
+		}
+		return errors.Wrap(sessions.Put([]byte(hashToken(id)), v), "error saving session")
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating session")
+	}
+
+	return session, nil
+}
+
+// AuthenticateSession returns session with given ID and account, which it belongs to
+func (store *Store) AuthenticateSession(id string) (*Account, *Session, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	session := &Session{}
+	err = db.View(func(tx *bolt.Tx) error {
+		sessions := system(tx, sessionsBucket)
+		if sessions == nil {
+			return ErrSessionNotFound
+		}
+		v := sessions.Get([]byte(hashToken(id)))
+		if v == nil {
+			return ErrSessionNotFound
+		}
+		if err := json.Unmarshal(v, session); err != nil {
+			return errors.Wrap(err, "error decoding session")
+		}
+		if time.Now().After(session.Expires) {
+			return ErrSessionExpired
+		}
+
+		record, err := loadAccount(tx, session.Account)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrSessionNotFound
+		}
+		if record.Suspended {
+			return ErrAccountSuspended
+		}
+		account = &record.Account
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error authenticating session")
+	}
+
+	return account, session, nil
+}
+
+// DeleteSession closes session, unknown sessions are ignored
+func (store *Store) DeleteSession(id string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		sessions := system(tx, sessionsBucket)
+		if sessions == nil {
+			return nil
+		}
+		return sessions.Delete([]byte(hashToken(id)))
+	})
+
+	return errors.Wrap(err, "error deleting session")
+}
+
+// DeleteExpiredSessions removes sessions, which could not be used anymore
+func (store *Store) DeleteExpiredSessions() error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	now := time.Now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		return deleteSessions(tx, func(session *Session) bool { return now.After(session.Expires) })
+	})
+
+	return errors.Wrap(err, "error deleting expired sessions")
+}
+
+// deleteSessions removes sessions matching fn
+// keys are collected first, because bucket should not be modified while iterating
+func deleteSessions(tx *bolt.Tx, fn func(session *Session) bool) error {
+	sessions := system(tx, sessionsBucket)
+	if sessions == nil {
+		return nil
+	}
+
+	matched := make([][]byte, 0)
+	err := sessions.ForEach(func(k, v []byte) error {
+		session := &Session{}
+		if err := json.Unmarshal(v, session); err != nil {
+			return errors.Wrap(err, "error decoding session")
+		}
+		if fn(session) {
+			matched = append(matched, copyValue(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range matched {
+		if err := sessions.Delete(k); err != nil {
+			return errors.Wrap(err, "error deleting session")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassword(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	_, err = s.CreateAccount("neo", "neo@zion.org", "neo token")
+	require.Nil(t, err)
+	_, err = s.CreateAccount("migrated", "", "old token")
+	require.Nil(t, err)
+
+	_, err = s.Login("neo@zion.org", "")
+	assert.Equal(t, ErrInvalidCredentials, errors.Cause(err))
+
+	assert.Equal(t, ErrWeakPassword, errors.Cause(s.SetPassword("neo", "short")))
+	assert.NotNil(t, s.SetPassword("migrated", "there is no spoon"))
+	require.Nil(t, s.SetPassword("neo", "there is no spoon"))
+
+	account, err := s.Login("NEO@zion.org", "there is no spoon")
+	require.Nil(t, err)
+	assert.Equal(t, "neo", account.ID)
+	_, err = s.Login("neo@zion.org", "there is a spoon")
+	assert.Equal(t, ErrInvalidCredentials, errors.Cause(err))
+	_, err = s.Login("smith@matrix.com", "there is no spoon")
+	assert.Equal(t, ErrInvalidCredentials, errors.Cause(err))
+
+	_, err = s.SetSuspended("neo", true)
+	require.Nil(t, err)
+	_, err = s.Login("neo@zion.org", "there is no spoon")
+	assert.Equal(t, ErrAccountSuspended, errors.Cause(err))
+
+	// password chosen on registration
+	_, err = s.CreateSignup("signup", "trinity@zion.org", "", "follow the white rabbit", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	_, err = s.CreateSignup("weak", "trinity@zion.org", "", "rabbit", time.Now().Add(time.Hour))
+	assert.Equal(t, ErrWeakPassword, errors.Cause(err))
+	_, err = s.ConfirmSignup("signup", "trinity", "trinity token")
+	require.Nil(t, err)
+	account, err = s.Login("trinity@zion.org", "follow the white rabbit")
+	require.Nil(t, err)
+	assert.Equal(t, "trinity", account.ID)
+}
+
+func TestPBKDF2(t *testing.T) {
+	// published PBKDF2-HMAC-SHA256 vectors (RFC 7914 section 11 and draft-josefsson-pbkdf2-test-vectors),
+	// truncated to the single 32 bytes block
+	tt := []struct {
+		Password   string
+		Salt       string
+		Iterations int
+		Result     string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"},
+		{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1"},
+	}
+
+	for _, test := range tt {
+		result := pbkdf2([]byte(test.Password), []byte(test.Salt), test.Iterations)
+		assert.Equal(t, test.Result, hex.EncodeToString(result), test.Password)
+	}
+
+	// stored form is "<iterations>:<hex salt>:<hex hash>"
+	assert.True(t, checkPassword("4096:"+hex.EncodeToString([]byte("salt"))+":"+tt[4].Result, "password"))
+	assert.False(t, checkPassword("4096:"+hex.EncodeToString([]byte("salt"))+":"+tt[4].Result, "Password"))
+}
+
+func TestSession(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	_, err = s.CreateAccount("neo", "neo@zion.org", "neo token")
+	require.Nil(t, err)
+
+	session, err := s.CreateSession("neo", "session", "csrf", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, "csrf", session.CSRF)
+	_, err = s.CreateSession("neo", "expired", "csrf", time.Now().Add(-time.Minute))
+	require.Nil(t, err)
+	_, err = s.CreateSession("missing", "other", "csrf", time.Now().Add(time.Hour))
+	assert.Equal(t, ErrAccountNotFound, errors.Cause(err))
+
+	account, session, err := s.AuthenticateSession("session")
+	require.Nil(t, err)
+	assert.Equal(t, "neo", account.ID)
+	assert.Equal(t, "csrf", session.CSRF)
+
+	_, _, err = s.AuthenticateSession("expired")
+	assert.Equal(t, ErrSessionExpired, errors.Cause(err))
+	require.Nil(t, s.DeleteExpiredSessions())
+	_, _, err = s.AuthenticateSession("expired")
+	assert.Equal(t, ErrSessionNotFound, errors.Cause(err))
+
+	require.Nil(t, s.DeleteSession("session"))
+	_, _, err = s.AuthenticateSession("session")
+	assert.Equal(t, ErrSessionNotFound, errors.Cause(err))
+
+	// new password closes all sessions
+	_, err = s.CreateSession("neo", "session", "csrf", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	require.Nil(t, s.SetPassword("neo", "there is no spoon"))
+	_, _, err = s.AuthenticateSession("session")
+	assert.Equal(t, ErrSessionNotFound, errors.Cause(err))
+
+	// so do rotated and recovered tokens
+	_, err = s.CreateSession("neo", "session", "csrf", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	require.Nil(t, s.RotateToken("neo", "rotated token"))
+	_, _, err = s.AuthenticateSession("session")
+	assert.Equal(t, ErrSessionNotFound, errors.Cause(err))
+
+	_, err = s.CreateSession("neo", "session", "csrf", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	_, err = s.CreateRecovery("neo@zion.org", "code", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	_, err = s.Recover("code", "recovered token")
+	require.Nil(t, err)
+	_, _, err = s.AuthenticateSession("session")
+	assert.Equal(t, ErrSessionNotFound, errors.Cause(err))
+}
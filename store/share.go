@@ -0,0 +1,693 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrShareNotFound is returned for unknown share tokens and for shares of other owners
+	ErrShareNotFound  = errors.New("share not found")
+	ErrShareExpired   = errors.New("share expired")
+	ErrShareExhausted = errors.New("share download limit reached")
+	ErrSharePassword  = errors.New("invalid share password")
+	// ErrShareSourceGone is returned when shared node of live share was deleted
+	ErrShareSourceGone = errors.New("shared source no longer exists")
+	// ErrShareForbidden is returned for reading from drop box share
+	ErrShareForbidden = errors.New("share does not allow reading")
+)
+
+// share modes
+const (
+	// ShareSnapshot copies shared node at the moment of sharing
+	ShareSnapshot = "snapshot"
+	// ShareLive keeps only pointer to shared node, so later changes are visible
+	ShareLive = "live"
+	// ShareDropBox keeps pointer to shared folder, which accepts uploads, but could not be read
+	ShareDropBox = "dropbox"
+	// ShareSite keeps pointer to shared folder, which is served as static website (see GetSite)
+	ShareSite = "site"
+)
+
+// ShareInfo describes share, it is kept in system shares bucket under share token
+type ShareInfo struct {
+	Token string `json:"token"`
+	// Owner is collection, which created the share
+	Owner string `json:"owner"`
+	// Path is shared node inside owner collection, empty for the whole collection
+	Path    []string  `json:"path"`
+	Created time.Time `json:"created"`
+	// Accesses counts reads of shared data
+	Accesses int `json:"accesses"`
+	// Expires is time after which share is not available, nil means forever
+	Expires *time.Time `json:"expires,omitempty"`
+	// MaxDownloads limits number of reads, 0 means no limit
+	MaxDownloads int `json:"max_downloads,omitempty"`
+	// Protected reports if password is required for reading
+	Protected bool `json:"protected"`
+	// Mode is ShareSnapshot, ShareLive, ShareDropBox or ShareSite, empty value (older versions) means snapshot
+	Mode string `json:"mode,omitempty"`
+	// MaxSize limits size of single file uploaded to drop box, 0 means no limit
+	MaxSize int64 `json:"max_size,omitempty"`
+	// MaxUploads limits number of files uploaded to drop box, 0 means no limit
+	MaxUploads int `json:"max_uploads,omitempty"`
+	// Uploads lists files uploaded to drop box
+	Uploads []DropUpload `json:"uploads,omitempty"`
+	// Emails lists deliveries of share link
+	Emails []ShareEmail `json:"emails,omitempty"`
+}
+
+// ShareEmail describes delivery of share link to single recipient
+type ShareEmail struct {
+	Email  string    `json:"email"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Sent   time.Time `json:"sent"`
+}
+
+// ShareOptions restricts access to share, zero values mean no restriction
+type ShareOptions struct {
+	Expires      time.Time
+	MaxDownloads int
+	Password     string
+	// Mode is ShareSnapshot (default), ShareLive, ShareDropBox or ShareSite
+	Mode string
+	// MaxSize and MaxUploads are limits of drop box
+	MaxSize    int64
+	MaxUploads int
+}
+
+// shareRecord is stored form of share info
+// password hash is kept only here, so it is never returned outside of store
+type shareRecord struct {
+	ShareInfo
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// check returns error, if share could not be read with given password at given time
+func (record *shareRecord) check(password string, now time.Time) error {
+	return record.checkAccess(!record.Protected || checkPassword(record.PasswordHash, password), now)
+}
+
+// checkAccess returns error, if share could not be read at given time
+// unlocked reports if password of protected share was verified
+func (record *shareRecord) checkAccess(unlocked bool, now time.Time) error {
+	if record.Expires != nil && now.After(*record.Expires) {
+		return ErrShareExpired
+	}
+	if !unlocked {
+		return ErrSharePassword
+	}
+	if record.MaxDownloads > 0 && record.Accesses >= record.MaxDownloads {
+		return ErrShareExhausted
+	}
+
+	return nil
+}
+
+// readable reports if shared data could be read by share token
+func (record *shareRecord) readable() bool {
+	return record.Mode != ShareDropBox && record.Mode != ShareSite
+}
+
+// pointer reports if share keeps pointer to owner's node instead of copy
+func (record *shareRecord) pointer() bool {
+	return record.Mode == ShareLive || record.Mode == ShareDropBox || record.Mode == ShareSite
+}
+
+// Share makes element of given collection available by target token
+// in snapshot mode element is copied to target collection,
+// in live mode only pointer to the element is kept and data is resolved on every read
+// in drop box mode pointer to the folder is kept, folder accepts uploads (see DropFile), but could not be read
+// in site mode pointer to the folder is kept, folder is served as website (see GetSite)
+// reference to the share is kept in system bucket, so owner could find his shares later
+func (store *Store) Share(collection string, from []string, target string, opts ShareOptions) error {
+	mode := opts.Mode
+	if mode == "" {
+		mode = ShareSnapshot
+	}
+	if mode != ShareSnapshot && mode != ShareLive && mode != ShareDropBox && mode != ShareSite {
+		return errors.Errorf("unknown share mode \"%s\"", mode)
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error openiong database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		fromBucket := tx.Bucket([]byte(collection))
+		if fromBucket == nil {
+			return errors.Errorf("bucket \"%s\" not exists", collection)
+		}
+
+		// token should not clash with collections or other shares
+		existing, err := loadShare(tx, target)
+		if err != nil {
+			return err
+		}
+		if existing != nil || tx.Bucket([]byte(target)) != nil {
+			return errors.Errorf("token \"%s\" already used", target)
+		}
+
+		// create reference to shared elements
+		record := &shareRecord{
+			ShareInfo: ShareInfo{
+				Token:        target,
+				Owner:        collection,
+				Path:         from,
+				Created:      time.Now(),
+				MaxDownloads: opts.MaxDownloads,
+				Mode:         mode,
+				MaxSize:      opts.MaxSize,
+				MaxUploads:   opts.MaxUploads,
+			},
+		}
+		if !opts.Expires.IsZero() {
+			record.Expires = &opts.Expires
+		}
+		if opts.Password != "" {
+			record.Protected = true
+			record.PasswordHash = hashPassword(opts.Password)
+		}
+		if err := saveShare(tx, record); err != nil {
+			return err
+		}
+
+		if mode == ShareLive {
+			_, _, err := liveSource(tx, record)
+			return err
+		}
+		if mode == ShareDropBox || mode == ShareSite {
+			_, err := sharedFolder(tx, record)
+			return err
+		}
+
+		targetBucket, err := tx.CreateBucket([]byte(target))
+		if err != nil {
+			return errors.Wrap(err, "error creating new bucket")
+		}
+
+		if len(from) == 0 {
+			return copyChilds(fromBucket, targetBucket)
+		}
+
+		for _, bucketName := range from[:len(from)-1] {
+			fromBucket = fromBucket.Bucket([]byte(bucketName))
+			if fromBucket == nil {
+				return errors.Errorf("bucket \"%s\" not exists", bucketName)
+			}
+		}
+
+		// single file is shared
+		targetName := from[len(from)-1]
+		if fromBucket.Bucket([]byte(targetName)) == nil {
+			content := fromBucket.Get([]byte(targetName))
+			if content == nil {
+				return errors.Errorf("bucket \"%s\" not exists", targetName)
+			}
+			return targetBucket.Put([]byte(targetName), content)
+		}
+
+		return copyBucket(fromBucket.Bucket([]byte(targetName)), targetBucket, targetName)
+	})
+
+	return errors.Wrap(err, "error updating database")
+}
+
+// SharedNode is element read from share
+type SharedNode struct {
+	// Name is the name of element, it is empty for root of share of the whole collection
+	Name string
+	// File tells whether Data is file content or tree view of folder
+	File bool
+	Data []byte
+}
+
+// GetShared returns shared data the same way as Get does, but only for registered share tokens
+// share restrictions are checked with given password, every successful file download is counted in share info
+func (store *Store) GetShared(token string, password string, keys []string) ([]byte, error) {
+	node, err := store.OpenShared(token, password, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Data, nil
+}
+
+// OpenShared works like GetShared, but also tells what kind of element was read
+// root of file share is the file itself, so it could be downloaded by share token only
+func (store *Store) OpenShared(token string, password string, keys []string) (*SharedNode, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var result *SharedNode
+	err = db.Update(func(tx *bolt.Tx) error {
+		result, err = openShare(tx, token, password, keys)
+		return err
+	})
+
+	return result, errors.Wrap(err, "error getting shared elements")
+}
+
+// openShare does the reading for OpenShared inside already opened transaction
+func openShare(tx *bolt.Tx, token string, password string, keys []string) (*SharedNode, error) {
+	record, err := activeShare(tx, token)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrShareNotFound
+	}
+	if err := record.check(password, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return countedRead(tx, record, keys)
+}
+
+// countedRead reads shared element, which access was already checked, and counts the read in share info
+// only file downloads are counted, so browsing folders of share does not use up its download limit
+func countedRead(tx *bolt.Tx, record *shareRecord, keys []string) (*SharedNode, error) {
+	result, err := readShare(tx, record, keys)
+	if err != nil {
+		return nil, err
+	}
+	if !result.File {
+		return result, nil
+	}
+
+	record.Accesses += 1
+	return result, saveShare(tx, record)
+}
+
+// ListShares returns all shares created by owner
+func (store *Store) ListShares(owner string) ([]*ShareInfo, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	shares := make([]*ShareInfo, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		return forEachShare(tx, func(record *shareRecord) error {
+			if record.Owner == owner {
+				shares = append(shares, &record.ShareInfo)
+			}
+			return nil
+		})
+	})
+
+	return shares, errors.Wrap(err, "error listing shares")
+}
+
+// GetShare returns info of share created by owner
+func (store *Store) GetShare(owner string, token string) (*ShareInfo, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var info *ShareInfo
+	err = db.View(func(tx *bolt.Tx) error {
+		record, err := ownShare(tx, owner, token)
+		if err != nil {
+			return err
+		}
+		info = &record.ShareInfo
+		return nil
+	})
+
+	return info, errors.Wrap(err, "error getting share")
+}
+
+// AddShareEmails remembers deliveries of share link, only owner of the share is able to add them
+func (store *Store) AddShareEmails(owner string, token string, emails []ShareEmail) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		record, err := ownShare(tx, owner, token)
+		if err != nil {
+			return err
+		}
+
+		record.Emails = append(record.Emails, emails...)
+		return saveShare(tx, record)
+	})
+
+	return errors.Wrap(err, "error saving share emails")
+}
+
+// RevokeShare removes shared data together with reference to it
+// only owner of the share is able to revoke it
+func (store *Store) RevokeShare(owner string, token string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := ownShare(tx, owner, token); err != nil {
+			return err
+		}
+
+		return deleteShare(tx, token)
+	})
+
+	return errors.Wrap(err, "error revoking share")
+}
+
+// DeleteExpiredShares removes shares (both data and reference), which expired before now
+func (store *Store) DeleteExpiredShares() error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// tokens are collected first, because bucket should not be modified while iterating
+		now := time.Now()
+		expired := make([]string, 0)
+		err := forEachShare(tx, func(record *shareRecord) error {
+			if record.Expires != nil && now.After(*record.Expires) {
+				expired = append(expired, record.Token)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, token := range expired {
+			if err := deleteShare(tx, token); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return errors.Wrap(err, "error deleting expired shares")
+}
+
+// readShare returns shared data, root of share contains shared node itself (or childs for the whole collection)
+// root of file share is the file content
+func readShare(tx *bolt.Tx, record *shareRecord, keys []string) (*SharedNode, error) {
+	if !record.readable() {
+		return nil, ErrShareForbidden
+	}
+
+	parent, name, err := shareSource(tx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	// the whole collection is shared
+	if name == "" {
+		if len(keys) == 0 {
+			return &SharedNode{Data: []byte(nestedView(parent, ""))}, nil
+		}
+		return readNode(parent, keys)
+	}
+
+	if len(keys) == 0 {
+		if parent.Bucket([]byte(name)) == nil && parent.Get([]byte(name)) != nil {
+			return readNode(parent, []string{name})
+		}
+		return &SharedNode{Name: name, Data: []byte(entryView(parent, []byte(name), ""))}, nil
+	}
+	if keys[0] != name {
+		return nil, errors.Errorf("bucket \"%s\" not found", keys[0])
+	}
+
+	return readNode(parent, keys)
+}
+
+// readNode returns element pointed by keys inside given bucket, keys should not be empty
+func readNode(b *bolt.Bucket, keys []string) (*SharedNode, error) {
+	data, err := getNode(b, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// getNode already checked, that all buckets on the way exist
+	for _, key := range keys[:len(keys)-1] {
+		b = b.Bucket([]byte(key))
+	}
+	name := keys[len(keys)-1]
+
+	return &SharedNode{Name: name, File: b.Bucket([]byte(name)) == nil, Data: data}, nil
+}
+
+// shareSource returns bucket, which contains shared node, and name of that node
+// for live shares node is resolved inside owner collection, for snapshots inside share copy
+// for share of the whole collection empty name is returned
+func shareSource(tx *bolt.Tx, record *shareRecord) (*bolt.Bucket, string, error) {
+	if record.Mode == ShareLive {
+		return liveSource(tx, record)
+	}
+
+	parent := tx.Bucket([]byte(record.Token))
+	if parent == nil {
+		return nil, "", errors.Errorf("bucket \"%s\" not exists", record.Token)
+	}
+	if len(record.Path) == 0 {
+		return parent, "", nil
+	}
+
+	return parent, record.Path[len(record.Path)-1], nil
+}
+
+// sharedFolder returns folder shared as drop box or site
+func sharedFolder(tx *bolt.Tx, record *shareRecord) (*bolt.Bucket, error) {
+	parent, name, err := liveSource(tx, record)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return parent, nil
+	}
+
+	folder := parent.Bucket([]byte(name))
+	if folder == nil {
+		return nil, errors.Errorf("\"%s\" is not a folder", name)
+	}
+
+	return folder, nil
+}
+
+// liveSource returns bucket, which contains node shared in live mode, and name of that node
+// for share of the whole collection, collection bucket and empty name are returned
+func liveSource(tx *bolt.Tx, record *shareRecord) (*bolt.Bucket, string, error) {
+	b := tx.Bucket([]byte(record.Owner))
+	if b == nil {
+		return nil, "", ErrShareSourceGone
+	}
+	if len(record.Path) == 0 {
+		return b, "", nil
+	}
+
+	for _, key := range record.Path[:len(record.Path)-1] {
+		b = b.Bucket([]byte(key))
+		if b == nil {
+			return nil, "", ErrShareSourceGone
+		}
+	}
+
+	name := record.Path[len(record.Path)-1]
+	if b.Bucket([]byte(name)) == nil && b.Get([]byte(name)) == nil {
+		return nil, "", ErrShareSourceGone
+	}
+
+	return b, name, nil
+}
+
+// ownShare returns share record, when share exists and belongs to owner
+func ownShare(tx *bolt.Tx, owner string, token string) (*shareRecord, error) {
+	record, err := loadShare(tx, token)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.Owner != owner {
+		return nil, ErrShareNotFound
+	}
+
+	return record, nil
+}
+
+// deleteShare removes shared data and share reference
+func deleteShare(tx *bolt.Tx, token string) error {
+	if tx.Bucket([]byte(token)) != nil {
+		if err := tx.DeleteBucket([]byte(token)); err != nil {
+			return errors.Wrap(err, "error deleting shared bucket")
+		}
+	}
+
+	return errors.Wrap(system(tx, sharesBucket).Delete([]byte(token)), "error deleting share reference")
+}
+
+// forEachShare calls fn for every share record in system bucket
+func forEachShare(tx *bolt.Tx, fn func(record *shareRecord) error) error {
+	shares := system(tx, sharesBucket)
+	if shares == nil {
+		return nil
+	}
+
+	return shares.ForEach(func(k, v []byte) error {
+		record := &shareRecord{}
+		if err := json.Unmarshal(v, record); err != nil {
+			return errors.Wrap(err, "error decoding share info")
+		}
+		// token was not stored by older versions
+		record.Token = string(k)
+		return fn(record)
+	})
+}
+
+// loadShare returns decoded share record, nil is returned for unknown token
+func loadShare(tx *bolt.Tx, token string) (*shareRecord, error) {
+	shares := system(tx, sharesBucket)
+	if shares == nil {
+		return nil, nil
+	}
+
+	v := shares.Get([]byte(token))
+	if v == nil {
+		return nil, nil
+	}
+
+	record := &shareRecord{}
+	err := json.Unmarshal(v, record)
+	record.Token = token
+	return record, errors.Wrap(err, "error decoding share info")
+}
+
+// activeShare returns share record available for reading by token
+// nil is returned for unknown token and for shares of suspended accounts
+func activeShare(tx *bolt.Tx, token string) (*shareRecord, error) {
+	record, err := loadShare(tx, token)
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	owner, err := loadAccount(tx, record.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil && owner.Suspended {
+		return nil, nil
+	}
+
+	return record, nil
+}
+
+func saveShare(tx *bolt.Tx, record *shareRecord) error {
+	shares, err := createSystem(tx, sharesBucket)
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "error encoding share info")
+	}
+
+	return errors.Wrap(shares.Put([]byte(record.Token), v), "error creating share reference")
+}
+
+// sharedView searches system bucket for shares of given owner, than use share tokens to search for its view on top-level
+// shares are shown under sharedEntry
+func sharedView(tx *bolt.Tx, owner string, indent string) (string, error) {
+	result := ""
+	err := forEachShare(tx, func(record *shareRecord) error {
+		if record.Owner != owner {
+			return nil
+		}
+
+		result += indent + "  " + EscapeName(record.Token) + "\n"
+
+		if record.pointer() {
+			// source of live share could be deleted, than only token is shown
+			parent, name, err := liveSource(tx, record)
+			switch {
+			case err != nil:
+			case name == "":
+				result += nestedView(parent, indent+"  "+"  ")
+			default:
+				result += entryView(parent, []byte(name), indent+"  "+"  ")
+			}
+			return nil
+		}
+
+		// nestedBucket will be nil if share was removed
+		nestedBucket := tx.Bucket([]byte(record.Token))
+		if nestedBucket != nil {
+			result += nestedView(nestedBucket, indent+"  "+"  ")
+		}
+		return nil
+	})
+	if result == "" {
+		return "", err
+	}
+
+	return indent + sharedEntry + "\n" + result, err
+}
+
+// interface is needed due to possible input as "*bolt.Bucket" or "*bolt.Tx"
+type bucket interface {
+	Bucket([]byte) *bolt.Bucket
+	CreateBucket([]byte) (*bolt.Bucket, error)
+	CreateBucketIfNotExists([]byte) (*bolt.Bucket, error)
+	Put([]byte, []byte) error
+	ForEach(func([]byte, []byte) error) error
+}
+
+// copyBucket copies "source" bucket and all his childs inside "target"
+// bucket is created even if it is empty
+func copyBucket(source bucket, target bucket, name string) error {
+	newTarget, err := target.CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return errors.Wrap(err, "error creating bucket copy")
+	}
+
+	err = source.ForEach(func(k, v []byte) error {
+		nestedBucket := source.Bucket(k)
+		if nestedBucket == nil {
+			return newTarget.Put(k, v)
+		}
+
+		return copyBucket(nestedBucket, newTarget, string(k))
+	})
+
+	return errors.Wrap(err, "error while recursive copying")
+}
+
+// copyChilds copies "source" bucket childs to "target"
+// this function is needed for root copying
+func copyChilds(source *bolt.Bucket, target *bolt.Bucket) error {
+	err := source.ForEach(func(k, v []byte) error {
+		nestedBucket := source.Bucket(k)
+		if nestedBucket == nil {
+			return target.Put(k, v)
+		}
+
+		return copyBucket(nestedBucket, target, string(k))
+
+	})
+
+	return errors.Wrap(err, "error while copying childs")
+}
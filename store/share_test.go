@@ -0,0 +1,330 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShare(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	// "shared" is an ordinary name now
+	err = s.Put("public", []string{"shared", "file"}, strings.NewReader("mine"))
+	require.Nil(t, err)
+
+	err = s.Share("public", []string{"a", "b"}, "token", ShareOptions{})
+	require.Nil(t, err)
+
+	result, err := s.Get("token", []string{"b", "c", "Hello there"})
+	require.Nil(t, err)
+	assert.Equal(t, "General Kenobi", string(result))
+
+	result, err = s.Get("public", []string{"shared"})
+	require.Nil(t, err)
+	assert.Equal(t, "file\n", string(result))
+
+	result, err = s.Get("public", []string{})
+	require.Nil(t, err)
+
+	expected := "1\n" +
+		"  2\n" +
+		"The Ring\n" +
+		"a\n" +
+		"  b\n" +
+		"    c\n" +
+		"      Hello there\n" +
+		"shared\n" +
+		"  file\n" +
+		"/shared\n" +
+		"  token\n" +
+		"    b\n" +
+		"      c\n" +
+		"        Hello there\n"
+
+	assert.Equal(t, expected, string(result))
+
+	err = s.Share("public", []string{"invalid"}, "other", ShareOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestShareManagement(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Create("private"))
+	require.Nil(t, s.Share("public", []string{"1"}, "first", ShareOptions{}))
+	require.Nil(t, s.Share("public", []string{"a"}, "second", ShareOptions{}))
+	require.Nil(t, s.Share("private", []string{}, "third", ShareOptions{}))
+
+	shares, err := s.ListShares("public")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(shares))
+	assert.Equal(t, "first", shares[0].Token)
+	assert.Equal(t, []string{"1"}, shares[0].Path)
+	assert.Equal(t, "second", shares[1].Token)
+
+	// reads are counted
+	for i := 0; i < 3; i += 1 {
+		result, err := s.GetShared("first", "", []string{"1", "2"})
+		require.Nil(t, err)
+		assert.Equal(t, "0", string(result))
+	}
+	share, err := s.GetShare("public", "first")
+	require.Nil(t, err)
+	assert.Equal(t, 3, share.Accesses)
+
+	// collections could not be read as shares
+	_, err = s.GetShared("public", "", []string{"The Ring"})
+	assert.Equal(t, ErrShareNotFound, errors.Cause(err))
+
+	// shares of other owners are not visible
+	_, err = s.GetShare("public", "third")
+	assert.Equal(t, ErrShareNotFound, errors.Cause(err))
+	err = s.RevokeShare("public", "third")
+	assert.Equal(t, ErrShareNotFound, errors.Cause(err))
+
+	require.Nil(t, s.RevokeShare("public", "first"))
+	_, err = s.GetShared("first", "", []string{})
+	assert.Equal(t, ErrShareNotFound, errors.Cause(err))
+	_, err = s.Get("first", []string{})
+	assert.NotNil(t, err)
+
+	shares, err = s.ListShares("public")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(shares))
+	assert.Equal(t, "second", shares[0].Token)
+}
+
+func TestShareRestrictions(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Share("public", []string{"1"}, "limited", ShareOptions{MaxDownloads: 2}))
+	require.Nil(t, s.Share("public", []string{"1"}, "listed", ShareOptions{MaxDownloads: 1}))
+	require.Nil(t, s.Share("public", []string{"1"}, "protected", ShareOptions{Password: "secret"}))
+	require.Nil(t, s.Share("public", []string{"1"}, "expired", ShareOptions{Expires: time.Now().Add(-time.Second)}))
+	require.Nil(t, s.Share("public", []string{"1"}, "active", ShareOptions{Expires: time.Now().Add(time.Hour)}))
+
+	tt := []struct {
+		Token    string
+		Keys     []string
+		Password string
+		Error    error
+	}{
+		{"limited", []string{"1", "2"}, "", nil},
+		{"limited", []string{"1", "2"}, "", nil},
+		{"limited", []string{"1", "2"}, "", ErrShareExhausted},
+		// listing of shared folder is not a download
+		{"listed", []string{}, "", nil},
+		{"listed", []string{}, "", nil},
+		{"listed", []string{"1", "2"}, "", nil},
+		{"listed", []string{}, "", ErrShareExhausted},
+		{"protected", []string{}, "", ErrSharePassword},
+		{"protected", []string{}, "Secret", ErrSharePassword},
+		{"protected", []string{}, "secret", nil},
+		{"expired", []string{}, "", ErrShareExpired},
+		{"active", []string{}, "", nil},
+	}
+
+	for _, test := range tt {
+		_, err := s.GetShared(test.Token, test.Password, test.Keys)
+		assert.Equal(t, test.Error, errors.Cause(err), test.Token)
+	}
+
+	// password hash never leaves the store
+	share, err := s.GetShare("public", "protected")
+	require.Nil(t, err)
+	assert.True(t, share.Protected)
+
+	// and it is slow hash, like the one of account passwords
+	db, err := s.open()
+	require.Nil(t, err)
+	err = db.View(func(tx *bolt.Tx) error {
+		record, err := loadShare(tx, "protected")
+		if err != nil {
+			return err
+		}
+		assert.True(t, strings.HasPrefix(record.PasswordHash, strconv.Itoa(passwordIterations)+":"))
+		return nil
+	})
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+
+	require.Nil(t, s.DeleteExpiredShares())
+	shares, err := s.ListShares("public")
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(shares))
+	_, err = s.Get("expired", []string{})
+	assert.NotNil(t, err)
+}
+
+func TestLiveShare(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Share("public", []string{"a", "b"}, "live", ShareOptions{Mode: ShareLive}))
+	require.Nil(t, s.Share("public", []string{"a", "b"}, "snapshot", ShareOptions{Mode: ShareSnapshot}))
+	require.Nil(t, s.Share("public", []string{}, "root", ShareOptions{Mode: ShareLive}))
+
+	// live share does not copy data
+	_, err = s.Get("live", []string{})
+	assert.NotNil(t, err)
+
+	// later changes are visible only in live share
+	require.Nil(t, s.Put("public", []string{"a", "b", "new"}, strings.NewReader("fresh")))
+
+	tt := []struct {
+		Token  string
+		Keys   []string
+		Result string
+		Error  error
+	}{
+		{"live", []string{}, "b\n  c\n    Hello there\n  new\n", nil},
+		{"live", []string{"b"}, "c\n  Hello there\nnew\n", nil},
+		{"live", []string{"b", "new"}, "fresh", nil},
+		{"live", []string{"a"}, "", errors.New("bucket \"a\" not found")},
+		{"snapshot", []string{}, "b\n  c\n    Hello there\n", nil},
+		{"snapshot", []string{"b", "new"}, "", errors.New("bucket \"new\" not found")},
+		{"root", []string{"a", "b", "new"}, "fresh", nil},
+		{"root", []string{"1"}, "2\n", nil},
+	}
+
+	for _, test := range tt {
+		result, err := s.GetShared(test.Token, "", test.Keys)
+		if test.Error != nil {
+			require.NotNil(t, err)
+			assert.Equal(t, test.Error.Error(), errors.Cause(err).Error())
+			continue
+		}
+		require.Nil(t, err)
+		assert.Equal(t, test.Result, string(result))
+	}
+
+	// deleted source
+	require.Nil(t, s.Delete("public", []string{"a", "b"}))
+	_, err = s.GetShared("live", "", []string{})
+	assert.Equal(t, ErrShareSourceGone, errors.Cause(err))
+	result, err := s.GetShared("snapshot", "", []string{"b", "c"})
+	require.Nil(t, err)
+	assert.Equal(t, "Hello there\n", string(result))
+
+	// source is recreated
+	require.Nil(t, s.Mkdir("public", []string{"a", "b"}))
+	result, err = s.GetShared("live", "", []string{})
+	require.Nil(t, err)
+	assert.Equal(t, "b/\n", string(result))
+
+	// missing source and used tokens are rejected
+	assert.NotNil(t, s.Share("public", []string{"missing"}, "other", ShareOptions{Mode: ShareLive}))
+	assert.NotNil(t, s.Share("public", []string{"1"}, "live", ShareOptions{Mode: ShareLive}))
+	assert.NotNil(t, s.Share("public", []string{"1"}, "public", ShareOptions{Mode: ShareLive}))
+	assert.NotNil(t, s.Share("public", []string{"1"}, "other", ShareOptions{Mode: "mirror"}))
+}
+
+func TestDropBox(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Share("public", []string{"a", "b"}, "drop", ShareOptions{Mode: ShareDropBox, MaxSize: 5, MaxUploads: 3}))
+
+	// only folders could be drop boxes
+	assert.NotNil(t, s.Share("public", []string{"1", "2"}, "file", ShareOptions{Mode: ShareDropBox}))
+	assert.NotNil(t, s.Share("public", []string{"missing"}, "missing", ShareOptions{Mode: ShareDropBox}))
+
+	tt := []struct {
+		Name   string
+		Data   string
+		Result string
+		Error  error
+	}{
+		{"report.txt", "one", "report.txt", nil},
+		{"report.txt", "two", "report (1).txt", nil},
+		{"big", "too long", "", ErrDropTooLarge},
+		{"c", "three", "c (1)", nil},
+		{"other", "four", "", ErrDropBoxFull},
+	}
+
+	for _, test := range tt {
+		name, err := s.DropFile("drop", "", test.Name, "10.0.0.1", strings.NewReader(test.Data))
+		if test.Error != nil {
+			assert.Equal(t, test.Error, errors.Cause(err))
+			continue
+		}
+		require.Nil(t, err)
+		assert.Equal(t, test.Result, name)
+	}
+
+	// files are written into owner's folder
+	result, err := s.Get("public", []string{"a", "b", "report (1).txt"})
+	require.Nil(t, err)
+	assert.Equal(t, "two", string(result))
+
+	// drop box could not be read
+	_, err = s.GetShared("drop", "", []string{"b"})
+	assert.Equal(t, ErrShareForbidden, errors.Cause(err))
+
+	// uploads are recorded
+	share, err := s.GetShare("public", "drop")
+	require.Nil(t, err)
+	require.Len(t, share.Uploads, 3)
+	assert.Equal(t, "c (1)", share.Uploads[2].Name)
+	assert.Equal(t, int64(5), share.Uploads[2].Size)
+	assert.Equal(t, "10.0.0.1", share.Uploads[2].IP)
+	assert.False(t, share.Uploads[2].Uploaded.IsZero())
+
+	// other shares do not accept uploads
+	require.Nil(t, s.Share("public", []string{"a"}, "live", ShareOptions{Mode: ShareLive}))
+	_, err = s.DropFile("live", "", "file", "", strings.NewReader(""))
+	assert.Equal(t, ErrShareNotFound, errors.Cause(err))
+
+	// expired drop box
+	require.Nil(t, s.Share("public", []string{"a"}, "expired", ShareOptions{Mode: ShareDropBox, Expires: time.Now().Add(-time.Minute)}))
+	_, err = s.DropFile("expired", "", "file", "", strings.NewReader(""))
+	assert.Equal(t, ErrShareExpired, errors.Cause(err))
+}
+
+func TestFileShare(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.Share("public", []string{"1", "2"}, "snapshot", ShareOptions{}))
+	require.Nil(t, s.Share("public", []string{"1", "2"}, "live", ShareOptions{Mode: ShareLive}))
+	require.Nil(t, s.Share("public", []string{"a"}, "folder", ShareOptions{}))
+	assert.NotNil(t, s.Share("public", []string{"1", "missing"}, "missing", ShareOptions{}))
+
+	require.Nil(t, s.Put("public", []string{"1", "2"}, strings.NewReader("changed")))
+
+	tt := []struct {
+		Token string
+		Keys  []string
+		Node  SharedNode
+	}{
+		{"snapshot", []string{}, SharedNode{Name: "2", File: true, Data: []byte("0")}},
+		{"snapshot", []string{"2"}, SharedNode{Name: "2", File: true, Data: []byte("0")}},
+		{"live", []string{}, SharedNode{Name: "2", File: true, Data: []byte("changed")}},
+		{"folder", []string{}, SharedNode{Name: "a", Data: []byte("a\n  b\n    c\n      Hello there\n")}},
+		{"folder", []string{"a", "b", "c", "Hello there"}, SharedNode{Name: "Hello there", File: true, Data: []byte("General Kenobi")}},
+	}
+
+	for _, test := range tt {
+		node, err := s.OpenShared(test.Token, "", test.Keys)
+		require.Nil(t, err)
+		assert.Equal(t, test.Node.Name, node.Name)
+		assert.Equal(t, test.Node.File, node.File)
+		assert.Equal(t, string(test.Node.Data), string(node.Data))
+	}
+}
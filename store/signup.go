@@ -0,0 +1,179 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrSignupNotFound is returned for unknown, replaced and already confirmed signups
+	ErrSignupNotFound = errors.New("signup not found")
+	ErrSignupExpired  = errors.New("signup expired")
+)
+
+// Signup is registration waiting for confirmation of email ownership
+type Signup struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	// Invite is code used instead of whitelist, it is spent when signup is confirmed
+	Invite string `json:"invite,omitempty"`
+	// PasswordHash is set, when password was chosen on registration
+	PasswordHash string    `json:"password_hash,omitempty"`
+	Created      time.Time `json:"created"`
+	Expires      time.Time `json:"expires"`
+}
+
+// CreateSignup stores pending registration for email
+// previous pending signups of the same email are replaced, so only the last sent link works
+// password is optional, only its hash is kept until account is created
+func (store *Store) CreateSignup(id string, email string, invite string, password string, expires time.Time) (*Signup, error) {
+	signup := &Signup{ID: id, Email: email, Invite: invite, Created: time.Now(), Expires: expires}
+	if password != "" {
+		hash, err := hashAccountPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		signup.PasswordHash = hash
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		existing, err := accountByEmail(tx, email)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrAccountExists
+		}
+
+		signups, err := createSystem(tx, signupsBucket)
+		if err != nil {
+			return err
+		}
+		err = deleteSignups(signups, func(s *Signup) bool { return strings.EqualFold(s.Email, email) })
+		if err != nil {
+			return err
+		}
+
+		v, err := json.Marshal(signup)
+		if err != nil {
+			return errors.Wrap(err, "error encoding signup")
+		}
+		return errors.Wrap(signups.Put([]byte(id), v), "error saving signup")
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating signup")
+	}
+
+	return signup, nil
+}
+
+// ConfirmSignup creates account for pending signup in the same transaction, signup could be confirmed once
+// invite of signup is spent at the same time, so account is not created when invite was used up meanwhile
+func (store *Store) ConfirmSignup(signupID string, id string, token string) (*Account, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var account *Account
+	err = db.Update(func(tx *bolt.Tx) error {
+		signups := system(tx, signupsBucket)
+		if signups == nil {
+			return ErrSignupNotFound
+		}
+		v := signups.Get([]byte(signupID))
+		if v == nil {
+			return ErrSignupNotFound
+		}
+		signup := &Signup{}
+		if err := json.Unmarshal(v, signup); err != nil {
+			return errors.Wrap(err, "error decoding signup")
+		}
+		if time.Now().After(signup.Expires) {
+			return ErrSignupExpired
+		}
+
+		if err := signups.Delete([]byte(signupID)); err != nil {
+			return errors.Wrap(err, "error deleting signup")
+		}
+		record, err := createAccount(tx, id, signup.Email, token)
+		if err != nil {
+			return err
+		}
+		if signup.PasswordHash != "" {
+			record.PasswordHash = signup.PasswordHash
+			if err := saveAccount(tx, record); err != nil {
+				return err
+			}
+		}
+		if signup.Invite != "" {
+			if err := useInvite(tx, signup.Invite, record); err != nil {
+				return err
+			}
+		}
+		account = &record.Account
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error confirming signup")
+	}
+
+	return account, nil
+}
+
+// DeleteExpiredSignups removes signups, which were not confirmed in time
+func (store *Store) DeleteExpiredSignups() error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	now := time.Now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		signups := system(tx, signupsBucket)
+		if signups == nil {
+			return nil
+		}
+		return deleteSignups(signups, func(s *Signup) bool { return now.After(s.Expires) })
+	})
+
+	return errors.Wrap(err, "error deleting expired signups")
+}
+
+// deleteSignups removes signups matching fn
+// keys are collected first, because bucket should not be modified while iterating
+func deleteSignups(signups *bolt.Bucket, fn func(signup *Signup) bool) error {
+	matched := make([][]byte, 0)
+	err := signups.ForEach(func(k, v []byte) error {
+		signup := &Signup{}
+		if err := json.Unmarshal(v, signup); err != nil {
+			return errors.Wrap(err, "error decoding signup")
+		}
+		if fn(signup) {
+			matched = append(matched, copyValue(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range matched {
+		if err := signups.Delete(k); err != nil {
+			return errors.Wrap(err, "error deleting signup")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignup(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	_, err = s.CreateSignup("first", "neo@example.com", "", "", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	// repeated signup replaces pending one
+	_, err = s.CreateSignup("second", "Neo@example.com", "", "", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	_, err = s.CreateSignup("expired", "trinity@example.com", "", "", time.Now().Add(-time.Minute))
+	require.Nil(t, err)
+
+	_, err = s.ConfirmSignup("first", "id", "token")
+	assert.Equal(t, ErrSignupNotFound, errors.Cause(err))
+	_, err = s.ConfirmSignup("expired", "id", "token")
+	assert.Equal(t, ErrSignupExpired, errors.Cause(err))
+
+	account, err := s.ConfirmSignup("second", "id", "token")
+	require.Nil(t, err)
+	assert.Equal(t, "Neo@example.com", account.Email)
+	account, _, err = s.Authenticate("token")
+	require.Nil(t, err)
+	assert.Equal(t, "id", account.ID)
+
+	// signup is confirmed once, registered emails are refused
+	_, err = s.ConfirmSignup("second", "other", "other token")
+	assert.Equal(t, ErrSignupNotFound, errors.Cause(err))
+	_, err = s.CreateSignup("third", "neo@example.com", "", "", time.Now().Add(time.Hour))
+	assert.Equal(t, ErrAccountExists, errors.Cause(err))
+
+	require.Nil(t, s.DeleteExpiredSignups())
+	_, err = s.ConfirmSignup("expired", "id", "token")
+	assert.Equal(t, ErrSignupNotFound, errors.Cause(err))
+}
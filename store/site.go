@@ -0,0 +1,103 @@
+package store
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// special pages of site
+const (
+	SiteIndex    = "index.html"
+	SiteNotFound = "404.html"
+)
+
+// ErrSitePageNotFound is returned when neither requested page nor custom 404 page exist
+var ErrSitePageNotFound = errors.New("page not found")
+
+// SitePage is file served from site share
+type SitePage struct {
+	// Name is the name of served file, it could be used for guessing content type
+	Name string
+	Data []byte
+	// Index reports that keys point to folder and its index page is served
+	Index bool
+	// NotFound reports that custom 404 page is served instead of missing one
+	NotFound bool
+}
+
+// GetSite returns page of site share, keys are relative to shared folder
+// folders are served by their index page, listing of folder is never returned
+// when page is missing, custom 404 page from the root of the site is returned
+// site is served by read-only transactions, so pages are not counted as downloads
+func (store *Store) GetSite(token string, password string, keys []string) (*SitePage, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var page *SitePage
+	err = db.View(func(tx *bolt.Tx) error {
+		record, err := activeShare(tx, token)
+		if err != nil {
+			return err
+		}
+		if record == nil || record.Mode != ShareSite {
+			return ErrShareNotFound
+		}
+		if err := record.check(password, time.Now()); err != nil {
+			return err
+		}
+
+		root, err := sharedFolder(tx, record)
+		if err != nil {
+			return err
+		}
+
+		page = sitePage(root, keys)
+		if page != nil {
+			return nil
+		}
+
+		if v := root.Get([]byte(SiteNotFound)); v != nil {
+			page = &SitePage{Name: SiteNotFound, Data: copyValue(v), NotFound: true}
+			return nil
+		}
+		return ErrSitePageNotFound
+	})
+
+	return page, errors.Wrap(err, "error getting site page")
+}
+
+// sitePage searches for file or index page of folder pointed by keys, nil is returned if there is no such page
+func sitePage(b *bolt.Bucket, keys []string) *SitePage {
+	for i, key := range keys {
+		if nested := b.Bucket([]byte(key)); nested != nil {
+			b = nested
+			continue
+		}
+
+		// file could be only the last element, bolt returns nil for buckets
+		v := b.Get([]byte(key))
+		if v == nil || i != len(keys)-1 {
+			return nil
+		}
+		return &SitePage{Name: key, Data: copyValue(v)}
+	}
+
+	v := b.Get([]byte(SiteIndex))
+	if v == nil {
+		return nil
+	}
+
+	return &SitePage{Name: SiteIndex, Data: copyValue(v), Index: true}
+}
+
+// copyValue returns copy of value, which is safe to use after transaction end
+func copyValue(v []byte) []byte {
+	result := make([]byte, len(v))
+	copy(result, v)
+	return result
+}
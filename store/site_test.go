@@ -0,0 +1,63 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSite(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	for path, content := range map[string]string{
+		"www/index.html":          "home",
+		"www/style.css":           "body {}",
+		"www/docs/index.html":     "docs",
+		"www/docs/api/spec.json":  "{}",
+		"www/404.html":            "missing",
+		"plain/index.html/nested": "folder named as index",
+	} {
+		require.Nil(t, s.Put("public", strings.Split(path, "/"), strings.NewReader(content)))
+	}
+	require.Nil(t, s.Share("public", []string{"www"}, "site", ShareOptions{Mode: ShareSite}))
+	require.Nil(t, s.Share("public", []string{"plain"}, "plain", ShareOptions{Mode: ShareSite}))
+	require.Nil(t, s.Share("public", []string{"www"}, "live", ShareOptions{Mode: ShareLive}))
+
+	// only folders could be sites
+	assert.NotNil(t, s.Share("public", []string{"www", "style.css"}, "file", ShareOptions{Mode: ShareSite}))
+
+	tt := []struct {
+		Token string
+		Keys  []string
+		Page  SitePage
+		Error error
+	}{
+		{"site", []string{}, SitePage{Name: "index.html", Data: []byte("home"), Index: true}, nil},
+		{"site", []string{"style.css"}, SitePage{Name: "style.css", Data: []byte("body {}")}, nil},
+		{"site", []string{"docs"}, SitePage{Name: "index.html", Data: []byte("docs"), Index: true}, nil},
+		{"site", []string{"docs", "api", "spec.json"}, SitePage{Name: "spec.json", Data: []byte("{}")}, nil},
+		{"site", []string{"docs", "api"}, SitePage{Name: "404.html", Data: []byte("missing"), NotFound: true}, nil},
+		{"site", []string{"style.css", "more"}, SitePage{Name: "404.html", Data: []byte("missing"), NotFound: true}, nil},
+		{"plain", []string{}, SitePage{}, ErrSitePageNotFound},
+		{"live", []string{}, SitePage{}, ErrShareNotFound},
+	}
+
+	for _, test := range tt {
+		page, err := s.GetSite(test.Token, "", test.Keys)
+		if test.Error != nil {
+			assert.Equal(t, test.Error, errors.Cause(err))
+			continue
+		}
+		require.Nil(t, err)
+		assert.Equal(t, test.Page, *page)
+	}
+
+	// site could not be read as ordinary share
+	_, err = s.GetShared("site", "", []string{})
+	assert.Equal(t, ErrShareForbidden, errors.Cause(err))
+}
@@ -11,6 +11,11 @@ import (
 
 type Store struct {
 	Path string
+
+	// MaxNameLength limits length of single name in bytes, DefaultMaxNameLength is used when not set
+	MaxNameLength int
+	// MaxDepth limits number of names in path, DefaultMaxDepth is used when not set
+	MaxDepth int
 }
 
 // Open
@@ -38,6 +43,7 @@ func (store *Store) Drop() error {
 // 1. In case of last element is bucket, will return tree view of it
 // 2. In case of last element is file, will return file content
 // 3. Error either from invalid key or smth other
+// keys going through mount point are resolved inside mounted share
 func (store *Store) Get(collection string, keys []string) ([]byte, error) {
 	db, err := store.open()
 	if err != nil {
@@ -46,56 +52,83 @@ func (store *Store) Get(collection string, keys []string) ([]byte, error) {
 	defer db.Close()
 
 	var result []byte
+	var mount *mountRecord
 	err = db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(collection))
-		if b == nil {
-			return errors.Errorf("bucket \"%s\" not exists", collection)
+		mount, err = findMount(tx, collection, keys)
+		if err != nil || mount != nil {
+			return err
 		}
+		result, err = get(tx, collection, keys)
+		return err
+	})
 
-		// handle case for top level bucket
-		if len(keys) == 0 {
-			result, err = view(tx, b, "")
-			return errors.Wrap(err, "error viewing node")
-		}
+	// reads of mounted share are counted, so write transaction is needed
+	if err == nil && mount != nil {
+		err = db.Update(func(tx *bolt.Tx) error {
+			result, err = readMount(tx, mount, keys[len(mount.Path):])
+			return err
+		})
+	}
 
-		// skip last element, it will be checked after loop
-		for i := 0; i < len(keys)-1; i += 1 {
-			b = b.Bucket([]byte(keys[i]))
-			if b == nil {
-				return errors.Errorf("bucket \"%s\" not found", keys[i])
-			}
-		}
+	return result, errors.Wrap(err, "error getting elements from bucket")
+}
 
-		// check the last element (could be file or bucket)
-		lastElem := keys[len(keys)-1]
+// get does the search for Get inside already opened transaction
+func get(tx *bolt.Tx, collection string, keys []string) ([]byte, error) {
+	b := tx.Bucket([]byte(collection))
+	if b == nil {
+		return nil, errors.Errorf("bucket \"%s\" not exists", collection)
+	}
 
-		// if the last element is bucket
-		if b.Bucket([]byte(lastElem)) != nil {
-			result, err = view(tx, b.Bucket([]byte(lastElem)), "")
-			return errors.Wrap(err, "error creating view")
-		}
-		// if the last element is file
-		v := b.Get([]byte(lastElem))
-		if v != nil {
-			result = make([]byte, len(v))
-			copy(result, v)
-			return nil
+	// handle case for top level bucket
+	if len(keys) == 0 {
+		result, err := view(tx, b, collection)
+		return result, errors.Wrap(err, "error viewing node")
+	}
+
+	return getNode(b, keys)
+}
+
+// getNode searches for keys starting from given bucket, keys should not be empty
+func getNode(b *bolt.Bucket, keys []string) ([]byte, error) {
+	// skip last element, it will be checked after loop
+	for i := 0; i < len(keys)-1; i += 1 {
+		b = b.Bucket([]byte(keys[i]))
+		if b == nil {
+			return nil, errors.Errorf("bucket \"%s\" not found", keys[i])
 		}
+	}
 
-		return errors.Errorf("bucket \"%s\" not found", lastElem)
-	})
+	// check the last element (could be file or bucket)
+	lastElem := keys[len(keys)-1]
 
-	return result, errors.Wrap(err, "error getting elements from bucket")
+	// if the last element is bucket
+	if b.Bucket([]byte(lastElem)) != nil {
+		return []byte(nestedView(b.Bucket([]byte(lastElem)), "")), nil
+	}
+	// if the last element is file
+	v := b.Get([]byte(lastElem))
+	if v != nil {
+		result := make([]byte, len(v))
+		copy(result, v)
+		return result, nil
+	}
+
+	return nil, errors.Errorf("bucket \"%s\" not found", lastElem)
 }
 
 // Put creates bucket for each "key" passed in params, except for the last one
 // last one is used as file name
 // if only one "key" passed, file will be created in root directory
-// this function cannot create bucket without file, so reader is required
+// this function cannot create bucket without file, so reader is required (use Mkdir for empty folders)
 func (store *Store) Put(collection string, keys []string, file io.Reader) error {
-	// protect reserved name
-	if len(keys) > 0 && keys[0] == "shared" {
-		return errors.New("'shared' name is reserved")
+	if err := store.ValidatePath(keys); err != nil {
+		return err
+	}
+
+	f, err := ioutil.ReadAll(file)
+	if err != nil {
+		return errors.Wrap(err, "error reading file from reader")
 	}
 
 	db, err := store.open()
@@ -104,53 +137,215 @@ func (store *Store) Put(collection string, keys []string, file io.Reader) error
 	}
 	defer db.Close()
 
+	err = db.Update(func(tx *bolt.Tx) error {
+		return put(tx, collection, keys, f)
+	})
+
+	return errors.Wrap(err, "error updating database")
+}
+
+// put writes file content under given keys inside collection
+// missing buckets on the way are created, names already used by files are not overwritten
+func put(tx *bolt.Tx, collection string, keys []string, content []byte) error {
+	if len(keys) == 0 {
+		return errors.New("file name is not provided")
+	}
+
+	b := tx.Bucket([]byte(collection))
+	if b == nil {
+		return errors.Errorf("bucket \"%s\" not exists", collection)
+	}
+	if err := checkWritable(tx, collection, keys); err != nil {
+		return err
+	}
+
+	// skip last element, it will be checked after loop
+	// last element should be the file, other ones - folders
+	for i := 0; i < len(keys)-1; i += 1 {
+		// not possible to create bucket, if this name is used for file
+		if b.Get([]byte(keys[i])) != nil {
+			return errors.Errorf("name \"%s\" already used", keys[i])
+		}
+		var err error
+		b, err = b.CreateBucketIfNotExists([]byte(keys[i]))
+		if err != nil {
+			return errors.Wrap(err, "error opening bucket")
+		}
+	}
+
+	lastElem := keys[len(keys)-1]
+
+	// last element should not exists as bucket
+	if b.Bucket([]byte(lastElem)) != nil {
+		return errors.Errorf("name \"%s\" already used", lastElem)
+	}
+
+	return b.Put([]byte(lastElem), content)
+}
+
+// Mkdir creates bucket for each "key" passed in params, including the last one
+// works like "mkdir -p": existing buckets are kept, but names used by files could not be reused
+func (store *Store) Mkdir(collection string, keys []string) error {
+	if len(keys) == 0 {
+		return errors.New("folder name is not provided")
+	}
+	if err := store.ValidatePath(keys); err != nil {
+		return err
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
 	err = db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(collection))
 		if b == nil {
 			return errors.Errorf("bucket \"%s\" not exists", collection)
 		}
+		if err := checkWritable(tx, collection, keys); err != nil {
+			return err
+		}
 
-		// skip last element, it will be checked after loop
-		// last element should be the file, other ones - folders
-		for i := 0; i < len(keys)-1; i += 1 {
-			// not possible to create bucket, if this name is used for file
-			if b.Get([]byte(keys[i])) != nil {
-				return errors.Errorf("name \"%s\" already used", keys[i])
+		for _, key := range keys {
+			if b.Get([]byte(key)) != nil && b.Bucket([]byte(key)) == nil {
+				return errors.Errorf("name \"%s\" already used", key)
 			}
-			b, err = b.CreateBucketIfNotExists([]byte(keys[i]))
+			b, err = b.CreateBucketIfNotExists([]byte(key))
 			if err != nil {
-				return errors.Wrap(err, "error opening bucket")
+				return errors.Wrap(err, "error creating bucket")
 			}
 		}
 
-		lastElem := keys[len(keys)-1]
+		return nil
+	})
 
-		// last element should not exists as bucket
-		if b.Bucket([]byte(lastElem)) != nil {
-			return errors.Errorf("name \"%s\" already used", lastElem)
-		}
+	return errors.Wrap(err, "error updating database")
+}
 
-		f, err := ioutil.ReadAll(file)
+// ErrInvalidOffset is returned when write starts after the end of file
+var ErrInvalidOffset = errors.New("offset is beyond the end of file")
+
+// WriteAt overwrites part of existing file starting from offset
+// file could grow if written part goes over its end, but gaps are not allowed
+// returns size of file after write
+func (store *Store) WriteAt(collection string, keys []string, offset int64, file io.Reader) (int64, error) {
+	if err := store.ValidatePath(keys); err != nil {
+		return 0, err
+	}
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return 0, errors.Wrap(err, "error reading file from reader")
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return 0, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var size int64
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, name, err := fileParent(tx, collection, keys)
 		if err != nil {
-			return errors.Wrap(err, "error reading file from reader")
+			return err
+		}
+		current := b.Get([]byte(name))
+		if current == nil {
+			return errors.Errorf("file \"%s\" not found", name)
+		}
+		if offset > int64(len(current)) {
+			return ErrInvalidOffset
 		}
-		b.Put([]byte(lastElem), f)
 
-		return nil
+		// value returned by bolt points to mmaped memory, so it should be copied before changing
+		end := offset + int64(len(data))
+		if end < int64(len(current)) {
+			end = int64(len(current))
+		}
+		content := make([]byte, end)
+		copy(content, current)
+		copy(content[offset:], data)
+
+		size = int64(len(content))
+		return b.Put([]byte(name), content)
 	})
 
-	return errors.Wrap(err, "error updating database")
+	return size, errors.Wrap(err, "error updating database")
+}
+
+// Append adds content to the end of file in a single transaction
+// file is created (the same way as Put does) when it does not exist yet
+// returns size of file after write
+func (store *Store) Append(collection string, keys []string, file io.Reader) (int64, error) {
+	if err := store.ValidatePath(keys); err != nil {
+		return 0, err
+	}
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return 0, errors.Wrap(err, "error reading file from reader")
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return 0, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var size int64
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, name, err := fileParent(tx, collection, keys)
+		if errors.Cause(err) == ErrMountReadOnly {
+			return err
+		}
+		if err != nil || b.Get([]byte(name)) == nil {
+			size = int64(len(data))
+			return put(tx, collection, keys, data)
+		}
+
+		current := b.Get([]byte(name))
+		content := make([]byte, 0, len(current)+len(data))
+		content = append(append(content, current...), data...)
+
+		size = int64(len(content))
+		return b.Put([]byte(name), content)
+	})
+
+	return size, errors.Wrap(err, "error updating database")
+}
+
+// fileParent returns bucket which should contain file pointed by keys and name of that file
+func fileParent(tx *bolt.Tx, collection string, keys []string) (*bolt.Bucket, string, error) {
+	if len(keys) == 0 {
+		return nil, "", errors.New("file name is not provided")
+	}
+
+	b := tx.Bucket([]byte(collection))
+	if b == nil {
+		return nil, "", errors.Errorf("bucket \"%s\" not exists", collection)
+	}
+	if err := checkWritable(tx, collection, keys); err != nil {
+		return nil, "", err
+	}
+
+	for i := 0; i < len(keys)-1; i += 1 {
+		b = b.Bucket([]byte(keys[i]))
+		if b == nil {
+			return nil, "", errors.Errorf("bucket \"%s\" not found", keys[i])
+		}
+	}
+
+	return b, keys[len(keys)-1], nil
 }
 
 // Delete removes element from database
 // in case it is a bucket, remove this bucket and all elements under this bucket
 // bucket removes recursively
+// mount point is only detached, mounts inside removed bucket are detached as well
 func (store *Store) Delete(collection string, keys []string) error {
-	// protect reserved name
-	if len(keys) > 0 && keys[0] == "shared" {
-		return errors.New("'shared' name is reserved")
-	}
-
 	db, err := store.open()
 	if err != nil {
 		return errors.Wrap(err, "error opening database")
@@ -159,9 +354,26 @@ func (store *Store) Delete(collection string, keys []string) error {
 
 	err = db.Update(func(tx *bolt.Tx) error {
 		if len(keys) == 0 {
+			if err := deleteMounts(tx, collection); err != nil {
+				return err
+			}
 			return tx.DeleteBucket([]byte(collection))
 		}
 
+		mount, err := findMount(tx, collection, keys)
+		if err != nil {
+			return err
+		}
+		if mount != nil && len(mount.Path) == len(keys) {
+			return deleteMount(tx, collection, keys)
+		}
+		if mount != nil {
+			return ErrMountReadOnly
+		}
+		if err := detachMounts(tx, collection, keys); err != nil {
+			return err
+		}
+
 		b := tx.Bucket([]byte(collection))
 		if b == nil {
 			return errors.Errorf("bucket \"%s\" not exists", collection)
@@ -180,7 +392,7 @@ func (store *Store) Delete(collection string, keys []string) error {
 			return b.Delete([]byte(lastElem))
 		}
 
-		err := b.DeleteBucket([]byte(lastElem))
+		err = b.DeleteBucket([]byte(lastElem))
 		if err != nil && err != bolt.ErrIncompatibleValue {
 			return err
 		}
@@ -210,160 +422,58 @@ func (store *Store) Create(collection string) error {
 	return errors.Wrap(err, "error updating database")
 }
 
-// Copy element from given collection to target collection
-func (store *Store) Share(collection string, from []string, target string) error {
-	db, err := store.open()
-	if err != nil {
-		return errors.Wrap(err, "error openiong database")
-	}
-	defer db.Close()
-
-	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(target))
-
-		return errors.Wrap(err, "error creating new bucket")
-	})
-
-	if err != nil {
-		return errors.Wrap(err, "error sharing bucket")
-	}
-
-	err = db.Update(func(tx *bolt.Tx) error {
-		fromBucket := tx.Bucket([]byte(collection))
-		if err != nil {
-			return errors.Wrap(err, "error getting existing bucket")
-		}
-
-		shared, err := fromBucket.CreateBucketIfNotExists([]byte("shared"))
-		if err != nil {
-			return err
-		}
-
-		// create reference to shared elements
-		err = shared.Put([]byte(target), []byte(""))
-		if err != nil {
-			return errors.Wrap(err, "error creating shared bucket")
-		}
-
-		targetBucket := tx.Bucket([]byte(target))
-		if len(from) == 0 {
-			return copyChilds(fromBucket, targetBucket)
-		}
-
-		targetName := ""
-		for _, bucketName := range from {
-			targetName = bucketName
-			fromBucket = fromBucket.Bucket([]byte(bucketName))
-			if fromBucket == nil {
-				return errors.Errorf("bucket \"%s\" not exists", bucketName)
-			}
-		}
-
-		return copyBucket(fromBucket, targetBucket, targetName)
-	})
-
-	return errors.Wrap(err, "error updating database")
-}
+// names of system entries of root view, escaped names never contain "/",
+// so these entries could not be confused with elements of collection
+const (
+	sharedEntry  = "/shared"
+	mountedEntry = "/mounted"
+)
 
-// view
-func view(tx *bolt.Tx, b *bolt.Bucket, indent string) ([]byte, error) {
-	result := nestedView(b, indent)
+// view returns tree view of collection root, followed by shares created by collection owner
+// and by mount points of shares received from others
+func view(tx *bolt.Tx, b *bolt.Bucket, collection string) ([]byte, error) {
+	result := nestedView(b, "")
 
-	sharedResult, err := sharedView(tx, b, indent)
+	sharedResult, err := sharedView(tx, collection, "")
 	if sharedResult != "" {
 		result += sharedResult
 	}
+	if err != nil {
+		return []byte(result), errors.Wrap(err, "error creating view")
+	}
+
+	mountedResult, err := mountedView(tx, collection, "")
+	result += mountedResult
 
 	return []byte(result), errors.Wrap(err, "error creating view")
 }
 
 // nestedView runs throug every bucket recursively
 // in the end we'll get tree view of data
+// names are escaped, so every name takes exactly one line
+// empty buckets are marked with trailing "/", so they could be told apart from files
 func nestedView(b *bolt.Bucket, indent string) string {
 	var view string
 
 	c := b.Cursor()
 	for k, _ := c.First(); k != nil; k, _ = c.Next() {
-		// shared element will be processed after loop
-		if string(k) == "shared" {
-			continue
-		}
-
-		view += indent + string(k) + "\n"
-
-		// nestedBucket will be nil if "k" is'n bucket
-		nestedBucket := b.Bucket(k)
-		if nestedBucket != nil {
-			view += nestedView(nestedBucket, indent+"  ")
-		}
+		view += entryView(b, k, indent)
 	}
 
 	return view
 }
 
-// sharedView takes names from 'shared' node, than use this names to search for its view on top-level
-func sharedView(tx *bolt.Tx, b *bolt.Bucket, indent string) (string, error) {
-	shared := b.Bucket([]byte("shared"))
-	if shared == nil {
-		return "", nil
+// entryView returns tree view of single element "k" of bucket "b"
+func entryView(b *bolt.Bucket, k []byte, indent string) string {
+	// nestedBucket will be nil if "k" is'n bucket
+	nestedBucket := b.Bucket(k)
+	if nestedBucket == nil {
+		return indent + EscapeName(string(k)) + "\n"
 	}
 
-	result := ""
-
-	result += indent + "shared" + "\n"
-	c := shared.Cursor()
-	for k, _ := c.First(); k != nil; k, _ = c.Next() {
-		result += indent + "  " + string(k) + "\n"
-		// nestedBucket will be nil if "k" is'n bucket
-		nestedBucket := tx.Bucket(k)
-		if nestedBucket != nil {
-			result += nestedView(nestedBucket, indent+"  "+"  ")
-		}
+	nested := nestedView(nestedBucket, indent+"  ")
+	if nested == "" {
+		return indent + EscapeName(string(k)) + "/\n"
 	}
-
-	return result, nil
-}
-
-// interface is needed due to possible input as "*bolt.Bucket" or "*bolt.Tx"
-type bucket interface {
-	Bucket([]byte) *bolt.Bucket
-	CreateBucket([]byte) (*bolt.Bucket, error)
-	CreateBucketIfNotExists([]byte) (*bolt.Bucket, error)
-	Put([]byte, []byte) error
-	ForEach(func([]byte, []byte) error) error
-}
-
-// copyBucket copies "source" bucket and all his childs inside "target"
-func copyBucket(source bucket, target bucket, name string) error {
-	err := source.ForEach(func(k, v []byte) error {
-		newTarget, err := target.CreateBucketIfNotExists([]byte(name))
-		if err != nil {
-			return err
-		}
-
-		nestedBucket := source.Bucket(k)
-		if nestedBucket == nil {
-			return newTarget.Put(k, v)
-		}
-
-		return copyBucket(nestedBucket, newTarget, string(k))
-	})
-
-	return errors.Wrap(err, "error while recursive copying")
-}
-
-// copyChilds copies "source" bucket childs to "target"
-// this function is needed for root copying
-func copyChilds(source *bolt.Bucket, target *bolt.Bucket) error {
-	err := source.ForEach(func(k, v []byte) error {
-		nestedBucket := source.Bucket(k)
-		if nestedBucket == nil {
-			return target.Put(k, v)
-		}
-
-		return copyBucket(nestedBucket, target, string(k))
-
-	})
-
-	return errors.Wrap(err, "error while copying childs")
+	return indent + EscapeName(string(k)) + "\n" + nested
 }
@@ -276,7 +276,8 @@ func TestDelete(t *testing.T) {
 	result, err := s.Get("public", []string{})
 	require.Nil(t, err)
 
-	expected := "1\n" +
+	// bucket "1" is left empty after its only file was deleted
+	expected := "1/\n" +
 		"The Ring\n"
 
 	assert.Equal(t, expected, string(result))
@@ -304,3 +305,116 @@ func TestCreate(t *testing.T) {
 		assert.Equal(t, test.Result, string(result))
 	}
 }
+
+func TestWriteAt(t *testing.T) {
+	tt := []struct {
+		Keys   []string
+		Offset int64
+		Data   string
+		Size   int64
+		Result string
+		Error  error
+	}{
+		{[]string{"The Ring"}, 3, "gorgeous", 11, "My gorgeous", nil},
+		{[]string{"The Ring"}, 11, "!", 12, "My gorgeous!", nil},
+		{[]string{"The Ring"}, 3, "pre", 12, "My pregeous!", nil},
+		{[]string{"The Ring"}, 20, "gap", 0, "", ErrInvalidOffset},
+		{[]string{"1", "missing"}, 0, "data", 0, "", errors.New("error updating database: file \"missing\" not found")},
+	}
+
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	for _, test := range tt {
+		size, err := s.WriteAt("public", test.Keys, test.Offset, strings.NewReader(test.Data))
+		if test.Error != nil {
+			require.NotNil(t, err)
+			if errors.Cause(err) != test.Error {
+				assert.Equal(t, test.Error.Error(), err.Error())
+			}
+			continue
+		}
+		require.Nil(t, err)
+		assert.Equal(t, test.Size, size)
+
+		result, err := s.Get("public", test.Keys)
+		require.Nil(t, err)
+		assert.Equal(t, test.Result, string(result))
+	}
+}
+
+func TestAppend(t *testing.T) {
+	tt := []struct {
+		Keys   []string
+		Data   string
+		Size   int64
+		Result string
+	}{
+		{[]string{"logs", "today"}, "first\n", 6, "first\n"},
+		{[]string{"logs", "today"}, "second\n", 13, "first\nsecond\n"},
+		{[]string{"The Ring"}, "!", 12, "My precious!"},
+	}
+
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	for _, test := range tt {
+		size, err := s.Append("public", test.Keys, strings.NewReader(test.Data))
+		require.Nil(t, err)
+		assert.Equal(t, test.Size, size)
+
+		result, err := s.Get("public", test.Keys)
+		require.Nil(t, err)
+		assert.Equal(t, test.Result, string(result))
+	}
+
+	_, err = s.Append("public", []string{"a"}, strings.NewReader("folder"))
+	assert.NotNil(t, err)
+}
+
+func TestMkdir(t *testing.T) {
+	tt := []struct {
+		Keys  []string
+		Error error
+	}{
+		{[]string{"empty"}, nil},
+		{[]string{"deep", "nested", "folder"}, nil},
+		{[]string{"a", "b"}, nil},
+		{[]string{"The Ring", "inside"}, errors.New("error updating database: name \"The Ring\" already used")},
+		{[]string{"shared"}, nil},
+	}
+
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	for _, test := range tt {
+		err := s.Mkdir("public", test.Keys)
+		if test.Error != nil {
+			require.NotNil(t, err)
+			assert.Equal(t, test.Error.Error(), err.Error())
+			continue
+		}
+		require.Nil(t, err)
+	}
+
+	result, err := s.Get("public", []string{})
+	require.Nil(t, err)
+
+	expected := "1\n" +
+		"  2\n" +
+		"The Ring\n" +
+		"a\n" +
+		"  b\n" +
+		"    c\n" +
+		"      Hello there\n" +
+		"deep\n" +
+		"  nested\n" +
+		"    folder/\n" +
+		"empty/\n" +
+		"shared/\n"
+
+	assert.Equal(t, expected, string(result))
+}
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// systemBucket is top-level bucket, which keeps all internal bookkeeping away from user data
+// name starts with NUL byte, it can come neither from validated path nor from request header,
+// so it never clashes with collections
+const systemBucket = "\x00system"
+
+// child buckets of systemBucket
+const (
+	metaBucket     = "meta"
+	sharesBucket   = "shares"
+	uploadsBucket  = "uploads"
+	mountsBucket   = "mounts"
+	aliasesBucket  = "aliases"
+	accountsBucket = "accounts"
+	// tokensBucket maps token hashes to account IDs
+	tokensBucket = "tokens"
+	// emailsBucket maps lower-cased emails to account IDs
+	emailsBucket = "emails"
+	// apiKeysBucket maps token hashes of API keys to their records
+	apiKeysBucket = "apikeys"
+	// recoveriesBucket maps hashes of one-time recovery codes to accounts
+	recoveriesBucket = "recoveries"
+	// signupsBucket keeps registrations waiting for email verification
+	signupsBucket = "signups"
+	// whitelistBucket keeps registration rules
+	whitelistBucket = "whitelist"
+	invitesBucket   = "invites"
+	// sessionsBucket maps hashes of session IDs to sessions
+	sessionsBucket = "sessions"
+	// presignedBucket keeps hashes of signatures of used presigned urls until they expire
+	presignedBucket = "presigned"
+)
+
+// migrations are applied one by one to databases, created by older versions
+// index of migration + 1 is the schema version it leads to
+var migrations = []func(tx *bolt.Tx) error{
+	migrateSharedBuckets,
+	migrateAccounts,
+}
+
+// system returns child of system bucket, nil is returned if it was not created yet
+func system(tx *bolt.Tx, name string) *bolt.Bucket {
+	b := tx.Bucket([]byte(systemBucket))
+	if b == nil {
+		return nil
+	}
+
+	return b.Bucket([]byte(name))
+}
+
+// createSystem returns child of system bucket, creating it when needed
+func createSystem(tx *bolt.Tx, name string) (*bolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(systemBucket))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating system bucket")
+	}
+
+	b, err = b.CreateBucketIfNotExists([]byte(name))
+	return b, errors.Wrapf(err, "error creating system bucket \"%s\"", name)
+}
+
+// Migrate brings database created by older version to current schema
+// applied migrations are remembered, so it is safe to call it on every start
+func (store *Store) Migrate() error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := createSystem(tx, metaBucket)
+		if err != nil {
+			return err
+		}
+
+		version := 0
+		if v := meta.Get([]byte("version")); v != nil {
+			version, err = strconv.Atoi(string(v))
+			if err != nil {
+				return errors.Wrap(err, "error parsing schema version")
+			}
+		}
+
+		for ; version < len(migrations); version += 1 {
+			if err := migrations[version](tx); err != nil {
+				return errors.Wrapf(err, "error migrating to version %d", version+1)
+			}
+		}
+
+		return meta.Put([]byte("version"), []byte(strconv.Itoa(version)))
+	})
+
+	return errors.Wrap(err, "error migrating database")
+}
+
+// migrateSharedBuckets moves share references from "shared" child bucket of every collection
+// into system shares bucket
+func migrateSharedBuckets(tx *bolt.Tx) error {
+	// collections are collected first, because buckets should not be modified while iterating
+	collections := make([]string, 0)
+	err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if string(name) != systemBucket && b.Bucket([]byte("shared")) != nil {
+			collections = append(collections, string(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "error searching shared buckets")
+	}
+
+	for _, collection := range collections {
+		b := tx.Bucket([]byte(collection))
+		err := b.Bucket([]byte("shared")).ForEach(func(token, v []byte) error {
+			// reference to share, which does not exist anymore
+			if tx.Bucket(token) == nil {
+				return nil
+			}
+			// source path was not stored by older versions
+			return saveShare(tx, &shareRecord{ShareInfo: ShareInfo{Token: string(token), Owner: collection}})
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error moving shares of \"%s\"", collection)
+		}
+
+		if err := b.DeleteBucket([]byte("shared")); err != nil {
+			return errors.Wrapf(err, "error deleting shared bucket of \"%s\"", collection)
+		}
+	}
+
+	return nil
+}
+
+// migrateAccounts registers account for every collection, created when token was the bucket name
+// collection is moved to bucket named by new account ID and hash of old name becomes account token,
+// so users keep their tokens, references from shares, mounts, aliases and uploads are moved as well
+// older versions did not keep emails, so accounts are created without one, owners could set it later with SetEmail
+func migrateAccounts(tx *bolt.Tx) error {
+	// everything at top level except system bucket and snapshot shares is collection
+	collections := make([]string, 0)
+	err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if string(name) == systemBucket {
+			return nil
+		}
+		record, err := loadShare(tx, string(name))
+		if err != nil || record != nil {
+			return err
+		}
+		collections = append(collections, string(name))
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "error searching collections")
+	}
+
+	ids := make(map[string]string, len(collections))
+	for _, token := range collections {
+		id := randomID()
+		ids[token] = id
+
+		b, err := tx.CreateBucket([]byte(id))
+		if err != nil {
+			return errors.Wrap(err, "error creating account bucket")
+		}
+		if err := copyChilds(tx.Bucket([]byte(token)), b); err != nil {
+			return err
+		}
+		if err := tx.DeleteBucket([]byte(token)); err != nil {
+			return errors.Wrap(err, "error deleting legacy collection")
+		}
+
+		record := &accountRecord{Account: Account{ID: id, Created: time.Now()}, TokenHash: hashToken(token)}
+		if err := saveAccount(tx, record); err != nil {
+			return err
+		}
+	}
+
+	return migrateOwners(tx, ids)
+}
+
+// migrateOwners replaces collection names with account IDs in system records
+// records are collected first, because buckets should not be modified while iterating
+func migrateOwners(tx *bolt.Tx, ids map[string]string) error {
+	shares := make([]*shareRecord, 0)
+	err := forEachShare(tx, func(record *shareRecord) error {
+		if id, ok := ids[record.Owner]; ok {
+			record.Owner = id
+			shares = append(shares, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "error searching shares")
+	}
+	for _, record := range shares {
+		if err := saveShare(tx, record); err != nil {
+			return err
+		}
+	}
+
+	if b := system(tx, aliasesBucket); b != nil {
+		aliases := make([]*AliasInfo, 0)
+		err := b.ForEach(func(k, v []byte) error {
+			info := &AliasInfo{}
+			if err := json.Unmarshal(v, info); err != nil {
+				return errors.Wrap(err, "error decoding alias")
+			}
+			if id, ok := ids[info.Owner]; ok {
+				info.Owner = id
+				aliases = append(aliases, info)
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "error searching aliases")
+		}
+		for _, info := range aliases {
+			if err := saveAlias(tx, info); err != nil {
+				return err
+			}
+		}
+	}
+
+	if mounts := system(tx, mountsBucket); mounts != nil {
+		for token, id := range ids {
+			legacy := mounts.Bucket([]byte(token))
+			if legacy == nil {
+				continue
+			}
+			b, err := mounts.CreateBucketIfNotExists([]byte(id))
+			if err != nil {
+				return errors.Wrap(err, "error creating mounts bucket")
+			}
+			if err := copyChilds(legacy, b); err != nil {
+				return err
+			}
+			if err := mounts.DeleteBucket([]byte(token)); err != nil {
+				return errors.Wrap(err, "error deleting legacy mounts bucket")
+			}
+		}
+	}
+
+	if uploads := system(tx, uploadsBucket); uploads != nil {
+		changed := make(map[string]*Upload)
+		err := uploads.ForEach(func(k, v []byte) error {
+			b := uploads.Bucket(k)
+			if b == nil || b.Get([]byte("info")) == nil {
+				return nil
+			}
+			upload, err := uploadInfo(b)
+			if err != nil {
+				return err
+			}
+			if id, ok := ids[upload.Collection]; ok {
+				upload.Collection = id
+				changed[string(k)] = upload
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "error searching uploads")
+		}
+		for k, upload := range changed {
+			if err := putUploadInfo(uploads.Bucket([]byte(k)), upload); err != nil {
+				return errors.Wrap(err, "error saving upload info")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	// layout of older versions: share references in "shared" child of collection
+	db, err := bolt.Open(DB_PATH, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	require.Nil(t, err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		target, err := tx.CreateBucket([]byte("token"))
+		if err != nil {
+			return err
+		}
+		if err := target.Put([]byte("The Ring"), []byte("My precious")); err != nil {
+			return err
+		}
+
+		shared, err := tx.Bucket([]byte("public")).CreateBucket([]byte("shared"))
+		if err != nil {
+			return err
+		}
+		if err := shared.Put([]byte("token"), []byte("")); err != nil {
+			return err
+		}
+		return shared.Put([]byte("removed"), []byte(""))
+	})
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+
+	// second run should not change anything
+	require.Nil(t, s.Migrate())
+	// collection is moved to account, old name stays valid as token
+	account, _, err := s.Authenticate("public")
+	require.Nil(t, err)
+	assert.NotEqual(t, "public", account.ID)
+	require.Nil(t, s.Mkdir(account.ID, []string{"shared"}))
+	require.Nil(t, s.Migrate())
+
+	result, err := s.Get(account.ID, []string{})
+	require.Nil(t, err)
+
+	expected := "1\n" +
+		"  2\n" +
+		"The Ring\n" +
+		"a\n" +
+		"  b\n" +
+		"    c\n" +
+		"      Hello there\n" +
+		"shared/\n" +
+		"/shared\n" +
+		"  token\n" +
+		"    The Ring\n"
+
+	assert.Equal(t, expected, string(result))
+
+	db, err = bolt.Open(DB_PATH, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	require.Nil(t, err)
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, "2", string(system(tx, metaBucket).Get([]byte("version"))))
+		assert.Nil(t, tx.Bucket([]byte("public")))
+		assert.NotNil(t, tx.Bucket([]byte("token")))
+
+		share, err := loadShare(tx, "token")
+		require.Nil(t, err)
+		assert.Equal(t, account.ID, share.Owner)
+
+		info, err := loadShare(tx, "removed")
+		assert.Nil(t, info)
+		return err
+	})
+	require.Nil(t, err)
+}
@@ -0,0 +1,298 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrUploadExpired  = errors.New("upload expired")
+	ErrUploadOffset   = errors.New("upload offset mismatch")
+	ErrUploadTooLarge = errors.New("upload exceeds declared length")
+)
+
+// Upload describes state of resumable upload
+type Upload struct {
+	ID         string    `json:"id"`
+	Collection string    `json:"collection"`
+	Keys       []string  `json:"keys"`
+	Length     int64     `json:"length"`
+	Offset     int64     `json:"offset"`
+	Expires    time.Time `json:"expires"`
+}
+
+// Done reports if all declared bytes were received
+func (u *Upload) Done() bool {
+	return u.Offset == u.Length
+}
+
+// chunksBucket keeps received chunks of upload keyed by their offset, so writing a chunk does not copy previous ones
+const chunksBucket = "chunks"
+
+// CreateUpload registers new upload with given id
+// data is staged in system uploads bucket until all bytes are received
+// expired uploads are cleaned up on the way
+func (store *Store) CreateUpload(collection string, keys []string, id string, length int64, expires time.Time) (*Upload, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("file name is not provided")
+	}
+	if err := store.ValidatePath(keys); err != nil {
+		return nil, err
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	upload := &Upload{
+		ID:         id,
+		Collection: collection,
+		Keys:       keys,
+		Length:     length,
+		Expires:    expires,
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(collection)) == nil {
+			return errors.Errorf("bucket \"%s\" not exists", collection)
+		}
+
+		uploads, err := createSystem(tx, uploadsBucket)
+		if err != nil {
+			return err
+		}
+
+		if err := deleteExpiredUploads(uploads, time.Now()); err != nil {
+			return err
+		}
+
+		b, err := uploads.CreateBucket([]byte(id))
+		if err != nil {
+			return errors.Wrap(err, "error creating upload bucket")
+		}
+
+		if _, err := b.CreateBucket([]byte(chunksBucket)); err != nil {
+			return errors.Wrap(err, "error creating chunks bucket")
+		}
+
+		return putUploadInfo(b, upload)
+	})
+
+	return upload, errors.Wrap(err, "error creating upload")
+}
+
+// GetUpload returns current state of upload owned by collection
+func (store *Store) GetUpload(collection string, id string) (*Upload, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var upload *Upload
+	err = db.View(func(tx *bolt.Tx) error {
+		_, upload, err = getUpload(tx, collection, id)
+		return err
+	})
+
+	return upload, errors.Wrap(err, "error getting upload")
+}
+
+// WriteUpload appends chunk to upload starting from given offset
+// when last byte is received, file is committed into collection with the same rules as Put
+func (store *Store) WriteUpload(collection string, id string, offset int64, chunk io.Reader) (*Upload, error) {
+	current, err := store.GetUpload(collection, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Offset != offset {
+		return nil, errors.Wrap(ErrUploadOffset, "error writing upload")
+	}
+
+	// read one byte more than allowed for detecting oversized chunks
+	// reading happens outside of transaction, so slow clients do not lock database
+	data, err := ioutil.ReadAll(io.LimitReader(chunk, current.Length-offset+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading chunk")
+	}
+	if int64(len(data)) > current.Length-offset {
+		return nil, errors.Wrap(ErrUploadTooLarge, "error writing upload")
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var upload *Upload
+	err = db.Update(func(tx *bolt.Tx) error {
+		var b *bolt.Bucket
+		b, upload, err = getUpload(tx, collection, id)
+		if err != nil {
+			return err
+		}
+		// offset could be changed by concurrent request
+		if upload.Offset != offset {
+			return ErrUploadOffset
+		}
+
+		chunks := b.Bucket([]byte(chunksBucket))
+		if len(data) > 0 {
+			if err := chunks.Put(chunkKey(offset), data); err != nil {
+				return errors.Wrap(err, "error writing chunk")
+			}
+		}
+		upload.Offset += int64(len(data))
+
+		if !upload.Done() {
+			return putUploadInfo(b, upload)
+		}
+
+		// chunks are joined only once, when the last one is received
+		content := make([]byte, 0, upload.Length)
+		err = chunks.ForEach(func(k, v []byte) error {
+			content = append(content, v...)
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "error reading chunks")
+		}
+
+		if err := put(tx, collection, upload.Keys, content); err != nil {
+			return errors.Wrap(err, "error committing upload")
+		}
+
+		return system(tx, uploadsBucket).DeleteBucket([]byte(id))
+	})
+
+	return upload, errors.Wrap(err, "error writing upload")
+}
+
+// DeleteUpload terminates upload and drops all received data
+func (store *Store) DeleteUpload(collection string, id string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, _, err := getUpload(tx, collection, id)
+		if err != nil && errors.Cause(err) != ErrUploadExpired {
+			return err
+		}
+
+		return system(tx, uploadsBucket).DeleteBucket([]byte(id))
+	})
+
+	return errors.Wrap(err, "error deleting upload")
+}
+
+// DeleteExpiredUploads removes all uploads which expired before now
+func (store *Store) DeleteExpiredUploads() error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		uploads := system(tx, uploadsBucket)
+		if uploads == nil {
+			return nil
+		}
+		return deleteExpiredUploads(uploads, time.Now())
+	})
+
+	return errors.Wrap(err, "error deleting expired uploads")
+}
+
+// getUpload returns upload bucket and its decoded info
+// uploads of other collections are reported as not found
+func getUpload(tx *bolt.Tx, collection string, id string) (*bolt.Bucket, *Upload, error) {
+	uploads := system(tx, uploadsBucket)
+	if uploads == nil {
+		return nil, nil, ErrUploadNotFound
+	}
+
+	b := uploads.Bucket([]byte(id))
+	if b == nil {
+		return nil, nil, ErrUploadNotFound
+	}
+
+	upload, err := uploadInfo(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if upload.Collection != collection {
+		return nil, nil, ErrUploadNotFound
+	}
+	if time.Now().After(upload.Expires) {
+		return nil, nil, ErrUploadExpired
+	}
+
+	return b, upload, nil
+}
+
+// chunkKey encodes offset as big-endian number, so bolt keeps chunks in upload order
+func chunkKey(offset int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(offset))
+	return key
+}
+
+func uploadInfo(b *bolt.Bucket) (*Upload, error) {
+	upload := &Upload{}
+	err := json.Unmarshal(b.Get([]byte("info")), upload)
+	return upload, errors.Wrap(err, "error decoding upload info")
+}
+
+func putUploadInfo(b *bolt.Bucket, upload *Upload) error {
+	info, err := json.Marshal(upload)
+	if err != nil {
+		return errors.Wrap(err, "error encoding upload info")
+	}
+
+	return b.Put([]byte("info"), info)
+}
+
+// deleteExpiredUploads removes uploads with expiration time before now
+// keys are collected first, because bucket should not be modified while iterating
+func deleteExpiredUploads(uploads *bolt.Bucket, now time.Time) error {
+	expired := make([][]byte, 0)
+	err := uploads.ForEach(func(k, v []byte) error {
+		b := uploads.Bucket(k)
+		if b == nil {
+			return nil
+		}
+		upload, err := uploadInfo(b)
+		if err != nil {
+			return err
+		}
+		if now.After(upload.Expires) {
+			expired = append(expired, copyValue(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "error searching expired uploads")
+	}
+
+	for _, k := range expired {
+		if err := uploads.DeleteBucket(k); err != nil {
+			return errors.Wrap(err, "error deleting expired upload")
+		}
+	}
+
+	return nil
+}
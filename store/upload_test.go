@@ -0,0 +1,122 @@
+package store
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpload(t *testing.T) {
+	tt := []struct {
+		Offset int64
+		Chunk  string
+		Done   bool
+		Error  error
+	}{
+		{0, "Hello ", false, nil},
+		{0, "Hello ", false, ErrUploadOffset},
+		{6, "there, General Kenobi", false, ErrUploadTooLarge},
+		{6, "there", true, nil},
+	}
+
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	upload, err := s.CreateUpload("public", []string{"greetings", "obi-wan"}, "id", 11, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), upload.Offset)
+
+	for _, test := range tt {
+		upload, err := s.WriteUpload("public", "id", test.Offset, strings.NewReader(test.Chunk))
+		if test.Error != nil {
+			require.NotNil(t, err)
+			assert.Equal(t, test.Error, errors.Cause(err))
+			continue
+		}
+		require.Nil(t, err)
+		assert.Equal(t, test.Offset+int64(len(test.Chunk)), upload.Offset)
+		assert.Equal(t, test.Done, upload.Done())
+	}
+
+	result, err := s.Get("public", []string{"greetings", "obi-wan"})
+	require.Nil(t, err)
+	assert.Equal(t, "Hello there", string(result))
+
+	// committed upload is removed from staging area
+	_, err = s.GetUpload("public", "id")
+	assert.Equal(t, ErrUploadNotFound, errors.Cause(err))
+}
+
+func TestUploadChunks(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	// chunks cross offsets, which differ in more than last byte of key
+	expected := &bytes.Buffer{}
+	for i := 0; i < 100; i++ {
+		expected.WriteString(strings.Repeat(string(rune('a'+i%26)), 7))
+	}
+	_, err = s.CreateUpload("public", []string{"big"}, "id", int64(expected.Len()), time.Now().Add(time.Hour))
+	require.Nil(t, err)
+
+	data := expected.Bytes()
+	for offset := 0; offset < len(data); offset += 7 {
+		upload, err := s.WriteUpload("public", "id", int64(offset), bytes.NewReader(data[offset:offset+7]))
+		require.Nil(t, err)
+		if !upload.Done() {
+			// every chunk is kept separately until commit
+			db, err := s.open()
+			require.Nil(t, err)
+			err = db.View(func(tx *bolt.Tx) error {
+				chunks := system(tx, uploadsBucket).Bucket([]byte("id")).Bucket([]byte(chunksBucket))
+				assert.Equal(t, offset/7+1, chunks.Stats().KeyN)
+				return nil
+			})
+			db.Close()
+			require.Nil(t, err)
+		}
+	}
+
+	result, err := s.Get("public", []string{"big"})
+	require.Nil(t, err)
+	assert.Equal(t, expected.String(), string(result))
+}
+
+func TestUploadAccess(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	err = s.Create("private")
+	require.Nil(t, err)
+
+	_, err = s.CreateUpload("public", []string{"file"}, "active", 10, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	_, err = s.CreateUpload("public", []string{"file"}, "expired", 10, time.Now().Add(-time.Hour))
+	require.Nil(t, err)
+
+	// uploads of other collections are not visible
+	_, err = s.GetUpload("private", "active")
+	assert.Equal(t, ErrUploadNotFound, errors.Cause(err))
+
+	_, err = s.GetUpload("public", "expired")
+	assert.Equal(t, ErrUploadExpired, errors.Cause(err))
+
+	err = s.DeleteExpiredUploads()
+	require.Nil(t, err)
+	_, err = s.GetUpload("public", "expired")
+	assert.Equal(t, ErrUploadNotFound, errors.Cause(err))
+
+	err = s.DeleteUpload("public", "active")
+	require.Nil(t, err)
+	_, err = s.GetUpload("public", "active")
+	assert.Equal(t, ErrUploadNotFound, errors.Cause(err))
+}
@@ -0,0 +1,277 @@
+package store
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// kinds of whitelist rules
+const (
+	// RuleExact matches single address
+	RuleExact = "exact"
+	// RuleDomain matches every address of domain, pattern looks like "*@example.com"
+	RuleDomain = "domain"
+	// RuleRegex matches addresses by regular expression
+	RuleRegex = "regex"
+)
+
+var (
+	ErrRuleNotFound = errors.New("whitelist rule not found")
+	ErrInvalidRule  = errors.New("invalid whitelist rule")
+	// ErrEmailNotListed means that email matches no allow rule, invite could be used instead
+	ErrEmailNotListed = errors.New("email is not whitelisted")
+	// ErrEmailDenied means that email matches deny rule, it could not register even with invite
+	ErrEmailDenied = errors.New("email is denied")
+)
+
+// WhitelistRule allows or denies registration for matching emails
+// matching is case-insensitive, deny rules win over allow rules
+type WhitelistRule struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`
+	Pattern string    `json:"pattern"`
+	Deny    bool      `json:"deny"`
+	Created time.Time `json:"created"`
+}
+
+// matches checks if email is covered by rule
+func (rule *WhitelistRule) matches(email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	switch rule.Kind {
+	case RuleExact:
+		return email == strings.ToLower(rule.Pattern)
+	case RuleDomain:
+		// address should have exactly one "@" and non-empty local part, domain is compared as a whole
+		at := strings.LastIndex(email, "@")
+		if at <= 0 || strings.Count(email, "@") != 1 {
+			return false
+		}
+		return email[at+1:] == strings.ToLower(strings.TrimPrefix(rule.Pattern, "*@"))
+	case RuleRegex:
+		// pattern was validated on creation
+		re, err := rule.regexp()
+		return err == nil && re.MatchString(email)
+	}
+	return false
+}
+
+// regexp compiles pattern of regex rule, it always matches the whole address,
+// so "ourcompany\.com" does not allow "x@ourcompany.com.evil.org"
+func (rule *WhitelistRule) regexp() (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)^(?:" + rule.Pattern + ")$")
+}
+
+// validate fills kind of rule when it is not set and checks pattern
+func (rule *WhitelistRule) validate() error {
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if rule.Kind == "" {
+		rule.Kind = RuleExact
+		if strings.HasPrefix(rule.Pattern, "*@") {
+			rule.Kind = RuleDomain
+		}
+	}
+
+	switch rule.Kind {
+	case RuleExact:
+		if !strings.Contains(rule.Pattern, "@") || strings.Contains(rule.Pattern, "*") {
+			return errors.Wrapf(ErrInvalidRule, "\"%s\" is not an email", rule.Pattern)
+		}
+	case RuleDomain:
+		if !strings.HasPrefix(rule.Pattern, "*@") || len(rule.Pattern) < 3 {
+			return errors.Wrapf(ErrInvalidRule, "domain pattern \"%s\" should look like \"*@example.com\"", rule.Pattern)
+		}
+	case RuleRegex:
+		// pattern is checked alone too, so it could not close the group it is wrapped into
+		if _, err := regexp.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+			return errors.Wrapf(ErrInvalidRule, "invalid regular expression \"%s\"", rule.Pattern)
+		}
+	default:
+		return errors.Wrapf(ErrInvalidRule, "unknown rule kind \"%s\"", rule.Kind)
+	}
+	return nil
+}
+
+// AddWhitelistRule stores registration rule, ID is provided by caller
+func (store *Store) AddWhitelistRule(rule WhitelistRule) (*WhitelistRule, error) {
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	rule.Created = time.Now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		return saveRule(tx, &rule)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error adding whitelist rule")
+	}
+
+	return &rule, nil
+}
+
+// ListWhitelistRules returns all registration rules, oldest first
+func (store *Store) ListWhitelistRules() ([]*WhitelistRule, error) {
+	db, err := store.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	var rules []*WhitelistRule
+	err = db.View(func(tx *bolt.Tx) error {
+		rules, err = loadRules(tx)
+		return err
+	})
+
+	return rules, errors.Wrap(err, "error listing whitelist rules")
+}
+
+// DeleteWhitelistRule removes registration rule by its ID
+func (store *Store) DeleteWhitelistRule(id string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := system(tx, whitelistBucket)
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrRuleNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+
+	return errors.Wrap(err, "error deleting whitelist rule")
+}
+
+// Whitelisted checks if email could be registered
+// email should match at least one allow rule and no deny rules
+func (store *Store) Whitelisted(email string) (bool, error) {
+	err := store.CheckWhitelist(email)
+	switch errors.Cause(err) {
+	case nil:
+		return true, nil
+	case ErrEmailNotListed, ErrEmailDenied:
+		return false, nil
+	}
+	return false, err
+}
+
+// CheckWhitelist returns nil for email, which could be registered
+// ErrEmailDenied is returned when email matches deny rule, ErrEmailNotListed when it matches no rules
+func (store *Store) CheckWhitelist(email string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		rules, err := loadRules(tx)
+		if err != nil {
+			return err
+		}
+
+		allowed := false
+		for _, rule := range rules {
+			if !rule.matches(email) {
+				continue
+			}
+			if rule.Deny {
+				return ErrEmailDenied
+			}
+			allowed = true
+		}
+		if !allowed {
+			return ErrEmailNotListed
+		}
+		return nil
+	})
+
+	return errors.Wrap(err, "error checking whitelist")
+}
+
+// SeedWhitelist adds exact or domain rules for given patterns, when whitelist was never seeded before
+// so rules removed later by admins are not restored on restart
+func (store *Store) SeedWhitelist(patterns []string) error {
+	db, err := store.open()
+	if err != nil {
+		return errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := createSystem(tx, metaBucket)
+		if err != nil {
+			return err
+		}
+		if meta.Get([]byte("whitelist_seeded")) != nil {
+			return nil
+		}
+
+		for _, pattern := range patterns {
+			if strings.TrimSpace(pattern) == "" {
+				continue
+			}
+			rule := &WhitelistRule{ID: randomID(), Pattern: pattern, Created: time.Now()}
+			if err := rule.validate(); err != nil {
+				return err
+			}
+			if err := saveRule(tx, rule); err != nil {
+				return err
+			}
+		}
+
+		return meta.Put([]byte("whitelist_seeded"), []byte("1"))
+	})
+
+	return errors.Wrap(err, "error seeding whitelist")
+}
+
+// loadRules returns decoded rules, oldest first
+func loadRules(tx *bolt.Tx) ([]*WhitelistRule, error) {
+	rules := make([]*WhitelistRule, 0)
+	b := system(tx, whitelistBucket)
+	if b == nil {
+		return rules, nil
+	}
+
+	err := b.ForEach(func(k, v []byte) error {
+		rule := &WhitelistRule{}
+		if err := json.Unmarshal(v, rule); err != nil {
+			return errors.Wrap(err, "error decoding whitelist rule")
+		}
+		rules = append(rules, rule)
+		return nil
+	})
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Created.Before(rules[j].Created) })
+	return rules, err
+}
+
+func saveRule(tx *bolt.Tx, rule *WhitelistRule) error {
+	b, err := createSystem(tx, whitelistBucket)
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(rule)
+	if err != nil {
+		return errors.Wrap(err, "error encoding whitelist rule")
+	}
+
+	return errors.Wrap(b.Put([]byte(rule.ID), v), "error saving whitelist rule")
+}
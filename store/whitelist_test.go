@@ -0,0 +1,85 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhitelist(t *testing.T) {
+	s, err := initStore()
+	require.Nil(t, err)
+	defer s.Drop()
+
+	require.Nil(t, s.SeedWhitelist([]string{"neo@example.com", " *@zion.org", ""}))
+	// seed is applied once
+	require.Nil(t, s.SeedWhitelist([]string{"smith@matrix.com"}))
+
+	_, err = s.AddWhitelistRule(WhitelistRule{ID: "regex", Kind: RuleRegex, Pattern: `^agent\.[a-z]+@matrix\.com$`})
+	require.Nil(t, err)
+	_, err = s.AddWhitelistRule(WhitelistRule{ID: "deny", Pattern: "cypher@zion.org", Deny: true})
+	require.Nil(t, err)
+	// regex without anchors still matches the whole address
+	_, err = s.AddWhitelistRule(WhitelistRule{ID: "unanchored", Kind: RuleRegex, Pattern: `[a-z]+@ourcompany\.com`})
+	require.Nil(t, err)
+
+	tt := []struct {
+		Email   string
+		Allowed bool
+	}{
+		{"neo@example.com", true},
+		{"NEO@Example.com", true},
+		{"trinity@zion.org", true},
+		{"cypher@zion.org", false},
+		{"trinity@evilzion.org", false},
+		{"a@evil@zion.org", false},
+		{"@zion.org", false},
+		{"Trinity@ZION.org", true},
+		{"agent.smith@matrix.com", true},
+		{"smith@matrix.com", false},
+		{"morpheus@example.com", false},
+		{"jane@ourcompany.com", true},
+		{"JANE@OurCompany.com", true},
+		{"x@ourcompany.com.evil.org", false},
+		{"evil.x@ourcompany.com", false},
+	}
+
+	for _, test := range tt {
+		allowed, err := s.Whitelisted(test.Email)
+		require.Nil(t, err)
+		assert.Equal(t, test.Allowed, allowed, test.Email)
+	}
+
+	// denied and unlisted emails are told apart, because only unlisted could use invites
+	assert.Equal(t, ErrEmailDenied, errors.Cause(s.CheckWhitelist("cypher@zion.org")))
+	assert.Equal(t, ErrEmailNotListed, errors.Cause(s.CheckWhitelist("morpheus@example.com")))
+	assert.Nil(t, s.CheckWhitelist("neo@example.com"))
+
+	invalid := []WhitelistRule{
+		{Pattern: "not an email"},
+		{Kind: RuleDomain, Pattern: "zion.org"},
+		{Kind: RuleRegex, Pattern: "(unclosed"},
+		{Kind: RuleRegex, Pattern: "a)|(b"},
+		{Kind: "glob", Pattern: "*"},
+	}
+	for _, rule := range invalid {
+		rule.ID = "invalid"
+		_, err := s.AddWhitelistRule(rule)
+		assert.Equal(t, ErrInvalidRule, errors.Cause(err), rule.Pattern)
+	}
+
+	rules, err := s.ListWhitelistRules()
+	require.Nil(t, err)
+	require.Len(t, rules, 5)
+	assert.Equal(t, RuleExact, rules[0].Kind)
+	assert.Equal(t, RuleDomain, rules[1].Kind)
+	assert.Equal(t, "*@zion.org", rules[1].Pattern)
+
+	require.Nil(t, s.DeleteWhitelistRule("deny"))
+	assert.Equal(t, ErrRuleNotFound, errors.Cause(s.DeleteWhitelistRule("deny")))
+	allowed, err := s.Whitelisted("cypher@zion.org")
+	require.Nil(t, err)
+	assert.True(t, allowed)
+}